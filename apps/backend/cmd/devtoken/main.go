@@ -0,0 +1,47 @@
+// Command devtoken mints ID tokens for a backend running with AUTH_MODE=local, so it can be
+// used and tested without a Firebase project. It reads only the LOCAL_JWT_* settings, the
+// same as the server; with RS256 keys it needs LOCAL_JWT_PRIVATE_KEY_FILE.
+//
+//	go run ./cmd/devtoken -uid alice -email alice@example.com
+//
+// The token is printed on stdout. Send it to /api/auth/signup once to register the user,
+// then as "Authorization: Bearer <token>".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/internal/authn"
+)
+
+func main() {
+	if err := config.LoadLocalAuthConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	uid := flag.String("uid", "", "ID of the user the token identifies")
+	email := flag.String("email", "", "email of the user; omit it for a guest")
+	ttl := flag.Duration("ttl", 24*time.Hour, "how long the token is valid")
+	flag.Parse()
+
+	if *uid == "" {
+		log.Fatal("-uid is required")
+	}
+	if *ttl <= 0 {
+		log.Fatal("-ttl must be positive")
+	}
+
+	keys, err := authn.LoadLocalKeys(config.AppConfig)
+	if err != nil {
+		log.Fatal(err)
+	}
+	token, err := authn.MintLocalToken(keys, config.AppConfig.LocalJWTIssuer, config.AppConfig.LocalJWTAudience, *uid, *email, *ttl)
+	if err != nil {
+		log.Fatalf("Failed to mint token: %v", err)
+	}
+	fmt.Println(token)
+}
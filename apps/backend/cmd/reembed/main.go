@@ -0,0 +1,53 @@
+// Command reembed backfills chunk embeddings for a new embedding model, so retrieval can
+// be switched to it without downtime:
+//
+//  1. Set EMBEDDING_NEXT_MODEL (and EMBEDDING_NEXT_DIMENSIONS) on the server, so newly
+//     uploaded documents are embedded with both models.
+//  2. Run this command until it reports no remaining chunks. It can run alongside the
+//     server, be stopped at any point and be started again.
+//  3. Make the new model the EMBEDDING_MODEL and unset EMBEDDING_NEXT_MODEL.
+package main
+
+import (
+	"flag"
+	"log"
+	"time"
+
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/internal/llm"
+	"strategic-insight-analyst/backend/services"
+)
+
+func main() {
+	if err := config.LoadConfig(); err != nil {
+		log.Fatal(err)
+	}
+
+	modelName := flag.String("embedding-model", config.AppConfig.EmbeddingNextModel, "embedding model to backfill (defaults to EMBEDDING_NEXT_MODEL)")
+	dimensions := flag.Int("dimensions", int(config.AppConfig.EmbeddingNextDimensions), "output dimensionality of the model (defaults to EMBEDDING_NEXT_DIMENSIONS)")
+	batchSize := flag.Int("batch-size", int(config.AppConfig.EmbeddingBatchSize), "chunks embedded per request")
+	flag.Parse()
+
+	if *modelName == "" {
+		log.Fatal("No model to backfill: pass -embedding-model or set EMBEDDING_NEXT_MODEL")
+	}
+	if *dimensions < 1 || *batchSize < 1 {
+		log.Fatal("-dimensions and -batch-size must be positive")
+	}
+
+	database.Connect()
+	database.Migrate()
+	if err := llm.Initialize(); err != nil {
+		log.Fatal(err)
+	}
+
+	model := llm.EmbeddingModel{Name: *modelName, Dimensions: *dimensions}
+	log.Printf("Backfilling %s embeddings", model)
+	start := time.Now()
+	embedded, err := services.BackfillEmbeddings(model, min(*batchSize, 100))
+	if err != nil {
+		log.Fatalf("Backfill stopped after %d chunks: %v", embedded, err)
+	}
+	log.Printf("Backfill complete: embedded %d chunks with %s in %s", embedded, model, time.Since(start).Round(time.Second))
+}
@@ -5,7 +5,9 @@ import (
 	"log"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -21,6 +23,63 @@ type Config struct {
 	GoogleApplicationCredentials string
 	GeminiAPIKey                 string
 	GCSBucketName                string
+
+	// Optional settings with defaults.
+	MaxUploadBytes    int64
+	URLImportMaxBytes int64
+	URLImportTimeout  time.Duration
+	// EmbeddingModel and EmbeddingDimensions select the embeddings used for ingestion and
+	// retrieval. While migrating to a new model, EmbeddingNextModel and EmbeddingNextDimensions
+	// name it so new chunks are embedded with both; the backfill command covers existing ones.
+	EmbeddingModel          string
+	EmbeddingDimensions     int64
+	EmbeddingNextModel      string
+	EmbeddingNextDimensions int64
+	// EmbeddingBatchSize is how many chunks are embedded per provider call (at most 100),
+	// and EmbeddingConcurrency how many batches of a document are embedded in parallel.
+	EmbeddingBatchSize   int64
+	EmbeddingConcurrency int64
+	// ResumableUploadTTL is how long an unfinished resumable upload is kept after its last write.
+	ResumableUploadTTL time.Duration
+	// GuestAccountTTL is how long guest accounts are kept after their creation unless
+	// upgraded to a full account; they are then deleted with their documents. 0 keeps them.
+	GuestAccountTTL time.Duration
+	// Retries, per-model rate limiting and circuit breaking of Gemini API calls.
+	LLMMaxRetries        int64
+	LLMRetryBaseDelay    time.Duration
+	LLMRetryMaxDelay     time.Duration
+	LLMRequestsPerMinute int64
+	LLMRateLimitBurst    int64
+	LLMBreakerThreshold  int64
+	LLMBreakerCooldown   time.Duration
+	// AuthModes are the ways ID tokens are verified, any of "firebase", "oidc", and "local"
+	// for development and tests without a Firebase project, with JWTs signed by the keys
+	// below. Tokens go to the mode trusting their issuer.
+	AuthModes []string
+	// LocalJWTSecret is the HS256 secret of the local mode. Alternatively, tokens are RS256
+	// signed: LocalJWTPublicKeyFile verifies them and LocalJWTPrivateKeyFile, needed only
+	// for minting, signs them. Both are PEM files.
+	LocalJWTSecret         string
+	LocalJWTPublicKeyFile  string
+	LocalJWTPrivateKeyFile string
+	LocalJWTIssuer         string
+	LocalJWTAudience       string
+	// OIDCIssuerURL is the issuer of an OpenID Connect provider such as Keycloak, Okta or
+	// Azure AD, whose keys are found through discovery unless OIDCJWKSURL is set. Tokens
+	// must be for OIDCAudience, usually the client ID.
+	OIDCIssuerURL string
+	OIDCAudience  string
+	OIDCJWKSURL   string
+	// OIDCJWKSCacheTTL is how long the provider's keys are cached before being refetched.
+	OIDCJWKSCacheTTL time.Duration
+	// OIDCEmailClaim and OIDCGroupsClaim name the claims holding the user's email and
+	// groups; nested claims are written as a dotted path, e.g. "realm_access.roles".
+	OIDCEmailClaim  string
+	OIDCGroupsClaim string
+	// OIDCRequireEmailVerified rejects emails of tokens without an email_verified claim.
+	// Providers such as Azure AD don't send it; for them it can be turned off to trust the
+	// configured issuer's email claim. Emails marked unverified are rejected either way.
+	OIDCRequireEmailVerified bool
 }
 
 // AppConfig is a global variable that holds the application configuration
@@ -47,6 +106,44 @@ func LoadConfig() error {
 		GCSBucketName:                os.Getenv("GCS_BUCKET_NAME"),
 	}
 
+	env := &envReader{}
+	AppConfig.MaxUploadBytes = env.int64("MAX_UPLOAD_BYTES", 500<<20)       // 500 MB
+	AppConfig.URLImportMaxBytes = env.int64("URL_IMPORT_MAX_BYTES", 50<<20) // 50 MB
+	AppConfig.URLImportTimeout = env.duration("URL_IMPORT_TIMEOUT", 30*time.Second)
+	AppConfig.EmbeddingModel = env.string("EMBEDDING_MODEL", "text-embedding-004")
+	AppConfig.EmbeddingDimensions = max(env.int64("EMBEDDING_DIMENSIONS", 768), 1)
+	AppConfig.EmbeddingNextModel = env.string("EMBEDDING_NEXT_MODEL", "")
+	AppConfig.EmbeddingNextDimensions = max(env.int64("EMBEDDING_NEXT_DIMENSIONS", AppConfig.EmbeddingDimensions), 1)
+	AppConfig.EmbeddingBatchSize = min(max(env.int64("EMBEDDING_BATCH_SIZE", 50), 1), 100)
+	AppConfig.EmbeddingConcurrency = max(env.int64("EMBEDDING_CONCURRENCY", 4), 1)
+	AppConfig.ResumableUploadTTL = env.duration("RESUMABLE_UPLOAD_TTL", 24*time.Hour)
+	AppConfig.GuestAccountTTL = env.duration("GUEST_ACCOUNT_TTL", 30*24*time.Hour)
+	AppConfig.LLMMaxRetries = env.int64("LLM_MAX_RETRIES", 4)
+	AppConfig.LLMRetryBaseDelay = env.duration("LLM_RETRY_BASE_DELAY", 500*time.Millisecond)
+	AppConfig.LLMRetryMaxDelay = env.duration("LLM_RETRY_MAX_DELAY", 30*time.Second)
+	AppConfig.LLMRequestsPerMinute = env.int64("LLM_REQUESTS_PER_MINUTE", 600)
+	AppConfig.LLMRateLimitBurst = max(env.int64("LLM_RATE_LIMIT_BURST", 10), 1)
+	AppConfig.LLMBreakerThreshold = env.int64("LLM_BREAKER_THRESHOLD", 5)
+	AppConfig.LLMBreakerCooldown = env.duration("LLM_BREAKER_COOLDOWN", 30*time.Second)
+	AppConfig.AuthModes = env.list("AUTH_MODE", "firebase")
+	env.localJWT(AppConfig)
+	AppConfig.OIDCIssuerURL = env.string("OIDC_ISSUER_URL", "")
+	AppConfig.OIDCAudience = env.string("OIDC_AUDIENCE", "")
+	AppConfig.OIDCJWKSURL = env.string("OIDC_JWKS_URL", "")
+	AppConfig.OIDCJWKSCacheTTL = env.duration("OIDC_JWKS_CACHE_TTL", time.Hour)
+	AppConfig.OIDCEmailClaim = env.string("OIDC_EMAIL_CLAIM", "email")
+	AppConfig.OIDCGroupsClaim = env.string("OIDC_GROUPS_CLAIM", "groups")
+	AppConfig.OIDCRequireEmailVerified = env.bool("OIDC_REQUIRE_EMAIL_VERIFIED", true)
+	for _, mode := range AppConfig.AuthModes {
+		if mode != "firebase" && mode != "oidc" && mode != "local" {
+			env.invalid = append(env.invalid, "AUTH_MODE")
+			break
+		}
+	}
+	if len(env.invalid) > 0 {
+		return fmt.Errorf("FATAL: invalid values for environment variables: %s", strings.Join(env.invalid, ", "))
+	}
+
 	// Validate that all required environment variables are set.
 	// Validate required fields
 	requiredVars := map[string]string{
@@ -81,8 +178,97 @@ func LoadConfig() error {
 	return nil
 }
 
+// LoadLocalAuthConfig loads only the LOCAL_JWT_* settings, for tools that mint local
+// tokens without the database, Gemini or GCS settings the server needs.
+func LoadLocalAuthConfig() error {
+	if err := godotenv.Load(); err != nil {
+		log.Println("Warning: .env file not found. Reading configuration from environment variables.")
+	}
+
+	AppConfig = &Config{}
+	env := &envReader{}
+	env.localJWT(AppConfig)
+	if len(env.invalid) > 0 {
+		return fmt.Errorf("FATAL: invalid values for environment variables: %s", strings.Join(env.invalid, ", "))
+	}
+	return nil
+}
+
 // GetDBDSN returns the full database connection string.
 func (c *Config) GetDBDSN() string {
 	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		c.PostgresHost, c.PostgresPort, c.PostgresUser, c.PostgresPassword, c.PostgresDB)
 }
+
+// envReader parses optional environment variables, recording the ones with invalid values.
+type envReader struct {
+	invalid []string
+}
+
+// localJWT reads the settings of AUTH_MODE=local into cfg.
+func (e *envReader) localJWT(cfg *Config) {
+	cfg.LocalJWTSecret = e.string("LOCAL_JWT_SECRET", "")
+	cfg.LocalJWTPublicKeyFile = e.string("LOCAL_JWT_PUBLIC_KEY_FILE", "")
+	cfg.LocalJWTPrivateKeyFile = e.string("LOCAL_JWT_PRIVATE_KEY_FILE", "")
+	cfg.LocalJWTIssuer = e.string("LOCAL_JWT_ISSUER", "strategic-insight-analyst-local")
+	cfg.LocalJWTAudience = e.string("LOCAL_JWT_AUDIENCE", "strategic-insight-analyst")
+}
+
+func (e *envReader) string(key, def string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// list splits a comma-separated value, dropping empty items.
+func (e *envReader) list(key, def string) []string {
+	var items []string
+	for _, item := range strings.Split(e.string(key, def), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func (e *envReader) int64(key string, def int64) int64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || parsed < 0 {
+		e.invalid = append(e.invalid, key)
+		return def
+	}
+	return parsed
+}
+
+// bool accepts the values strconv.ParseBool does, such as "true" or "0".
+func (e *envReader) bool(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		e.invalid = append(e.invalid, key)
+		return def
+	}
+	return parsed
+}
+
+// duration accepts Go duration strings such as "30s" or "5m".
+func (e *envReader) duration(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		e.invalid = append(e.invalid, key)
+		return def
+	}
+	return parsed
+}
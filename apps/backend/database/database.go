@@ -35,7 +35,7 @@ func Migrate() {
 	usersQuery := `
 	   CREATE TABLE IF NOT EXISTS users (
 	       id VARCHAR(255) PRIMARY KEY, -- Firebase Auth UID
-	       email VARCHAR(255) NOT NULL UNIQUE,
+	       email VARCHAR(255) UNIQUE, -- NULL for guests
 	       auth_method VARCHAR(50),
 	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
 	   );`
@@ -75,6 +75,278 @@ func Migrate() {
 		log.Fatal("Failed to create document_chunks table:", err)
 	}
 
+	uploadBatchesQuery := `
+	   CREATE TABLE IF NOT EXISTS upload_batches (
+	       id VARCHAR(255) PRIMARY KEY,
+	       user_id VARCHAR(255) NOT NULL,
+	       file_name VARCHAR(255) NOT NULL,
+	       skipped_files JSONB NOT NULL DEFAULT '[]',
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(uploadBatchesQuery); err != nil {
+		log.Fatal("Failed to create upload_batches table:", err)
+	}
+
+	resumableUploadsQuery := `
+	   CREATE TABLE IF NOT EXISTS resumable_uploads (
+	       id VARCHAR(255) PRIMARY KEY,
+	       user_id VARCHAR(255) NOT NULL,
+	       file_name VARCHAR(255) NOT NULL,
+	       file_type VARCHAR(255),
+	       size_bytes BIGINT NOT NULL,
+	       offset_bytes BIGINT NOT NULL DEFAULT 0,
+	       expires_at TIMESTAMP WITH TIME ZONE NOT NULL,
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(resumableUploadsQuery); err != nil {
+		log.Fatal("Failed to create resumable_uploads table:", err)
+	}
+
+	resumableUploadPartsQuery := `
+	   CREATE TABLE IF NOT EXISTS resumable_upload_parts (
+	       upload_id VARCHAR(255) NOT NULL,
+	       offset_bytes BIGINT NOT NULL,
+	       size_bytes BIGINT NOT NULL,
+	       object_name VARCHAR(255) NOT NULL,
+	       PRIMARY KEY (upload_id, offset_bytes),
+	       FOREIGN KEY (upload_id) REFERENCES resumable_uploads(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(resumableUploadPartsQuery); err != nil {
+		log.Fatal("Failed to create resumable_upload_parts table:", err)
+	}
+
+	// The embedding column has no fixed dimension so entries for different models can coexist.
+	embeddingCacheQuery := `
+	   CREATE TABLE IF NOT EXISTS embedding_cache (
+	       model VARCHAR(255) NOT NULL,
+	       dimensions INTEGER NOT NULL,
+	       text_hash VARCHAR(64) NOT NULL,
+	       embedding vector NOT NULL,
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       PRIMARY KEY (model, dimensions, text_hash)
+	   );`
+
+	if _, err := DB.Exec(embeddingCacheQuery); err != nil {
+		log.Fatal("Failed to create embedding_cache table:", err)
+	}
+
+	// Embeddings of chunks for models other than the one recorded on the chunk itself, written
+	// while migrating to a new embedding model.
+	chunkEmbeddingsQuery := `
+	   CREATE TABLE IF NOT EXISTS chunk_embeddings (
+	       chunk_id VARCHAR(255) NOT NULL,
+	       model VARCHAR(255) NOT NULL,
+	       dimensions INTEGER NOT NULL,
+	       embedding vector NOT NULL,
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       PRIMARY KEY (chunk_id, model, dimensions),
+	       FOREIGN KEY (chunk_id) REFERENCES document_chunks(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(chunkEmbeddingsQuery); err != nil {
+		log.Fatal("Failed to create chunk_embeddings table:", err)
+	}
+
+	// Collections nest through parent_id. Membership and tags refer to a document's first
+	// version, so they apply to all its versions.
+	collectionsQuery := `
+	   CREATE TABLE IF NOT EXISTS collections (
+	       id VARCHAR(255) PRIMARY KEY,
+	       user_id VARCHAR(255) NOT NULL,
+	       name VARCHAR(255) NOT NULL,
+	       parent_id VARCHAR(255),
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+	       FOREIGN KEY (parent_id) REFERENCES collections(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(collectionsQuery); err != nil {
+		log.Fatal("Failed to create collections table:", err)
+	}
+
+	documentCollectionsQuery := `
+	   CREATE TABLE IF NOT EXISTS document_collections (
+	       collection_id VARCHAR(255) NOT NULL,
+	       document_id VARCHAR(255) NOT NULL,
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       PRIMARY KEY (collection_id, document_id),
+	       FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+	       FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(documentCollectionsQuery); err != nil {
+		log.Fatal("Failed to create document_collections table:", err)
+	}
+
+	tagsQuery := `
+	   CREATE TABLE IF NOT EXISTS tags (
+	       id VARCHAR(255) PRIMARY KEY,
+	       user_id VARCHAR(255) NOT NULL,
+	       name VARCHAR(255) NOT NULL,
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(tagsQuery); err != nil {
+		log.Fatal("Failed to create tags table:", err)
+	}
+
+	documentTagsQuery := `
+	   CREATE TABLE IF NOT EXISTS document_tags (
+	       tag_id VARCHAR(255) NOT NULL,
+	       document_id VARCHAR(255) NOT NULL,
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       PRIMARY KEY (tag_id, document_id),
+	       FOREIGN KEY (tag_id) REFERENCES tags(id) ON DELETE CASCADE,
+	       FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(documentTagsQuery); err != nil {
+		log.Fatal("Failed to create document_tags table:", err)
+	}
+
+	// A share grants the recipient access to one of the owner's documents (by its first
+	// version) or collections, including its subcollections.
+	sharesQuery := `
+	   CREATE TABLE IF NOT EXISTS shares (
+	       id VARCHAR(255) PRIMARY KEY,
+	       owner_id VARCHAR(255) NOT NULL,
+	       recipient_id VARCHAR(255) NOT NULL,
+	       document_id VARCHAR(255),
+	       collection_id VARCHAR(255),
+	       permission VARCHAR(20) NOT NULL CHECK (permission IN ('viewer', 'chatter')),
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE,
+	       FOREIGN KEY (recipient_id) REFERENCES users(id) ON DELETE CASCADE,
+	       FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
+	       FOREIGN KEY (collection_id) REFERENCES collections(id) ON DELETE CASCADE,
+	       CHECK ((document_id IS NULL) <> (collection_id IS NULL))
+	   );`
+
+	if _, err := DB.Exec(sharesQuery); err != nil {
+		log.Fatal("Failed to create shares table:", err)
+	}
+
+	// Workspaces own the documents of a team. Documents without a workspace are in their
+	// uploader's personal space.
+	workspacesQuery := `
+	   CREATE TABLE IF NOT EXISTS workspaces (
+	       id VARCHAR(255) PRIMARY KEY,
+	       name VARCHAR(255) NOT NULL,
+	       created_by VARCHAR(255),
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (created_by) REFERENCES users(id) ON DELETE SET NULL
+	   );`
+
+	if _, err := DB.Exec(workspacesQuery); err != nil {
+		log.Fatal("Failed to create workspaces table:", err)
+	}
+
+	workspaceMembersQuery := `
+	   CREATE TABLE IF NOT EXISTS workspace_members (
+	       workspace_id VARCHAR(255) NOT NULL,
+	       user_id VARCHAR(255) NOT NULL,
+	       role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       PRIMARY KEY (workspace_id, user_id),
+	       FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+	       FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(workspaceMembersQuery); err != nil {
+		log.Fatal("Failed to create workspace_members table:", err)
+	}
+
+	// Invitations are addressed to an email, so people can be invited before they sign up.
+	workspaceInvitationsQuery := `
+	   CREATE TABLE IF NOT EXISTS workspace_invitations (
+	       id VARCHAR(255) PRIMARY KEY,
+	       workspace_id VARCHAR(255) NOT NULL,
+	       email VARCHAR(255) NOT NULL,
+	       role VARCHAR(20) NOT NULL CHECK (role IN ('owner', 'editor', 'viewer')),
+	       invited_by VARCHAR(255),
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (workspace_id) REFERENCES workspaces(id) ON DELETE CASCADE,
+	       FOREIGN KEY (invited_by) REFERENCES users(id) ON DELETE SET NULL
+	   );`
+
+	if _, err := DB.Exec(workspaceInvitationsQuery); err != nil {
+		log.Fatal("Failed to create workspace_invitations table:", err)
+	}
+
+	// API keys let scripts act as their owner. Only a SHA-256 hash of each key is stored;
+	// prefix is its start, shown to tell keys apart.
+	apiKeysQuery := `
+	   CREATE TABLE IF NOT EXISTS api_keys (
+	       id VARCHAR(255) PRIMARY KEY,
+	       user_id VARCHAR(255) NOT NULL,
+	       name VARCHAR(255) NOT NULL,
+	       prefix VARCHAR(32) NOT NULL,
+	       key_hash VARCHAR(64) NOT NULL UNIQUE,
+	       scopes TEXT[] NOT NULL,
+	       expires_at TIMESTAMP WITH TIME ZONE,
+	       last_used_at TIMESTAMP WITH TIME ZONE,
+	       created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+	       FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+	   );`
+
+	if _, err := DB.Exec(apiKeysQuery); err != nil {
+		log.Fatal("Failed to create api_keys table:", err)
+	}
+
+	// Columns added after the initial schema. ADD COLUMN IF NOT EXISTS keeps existing databases in sync.
+	alterQueries := []string{
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS parent_document_id VARCHAR(255) REFERENCES documents(id) ON DELETE CASCADE;",
+		"ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS metadata JSONB;",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS batch_id VARCHAR(255) REFERENCES upload_batches(id) ON DELETE SET NULL;",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS folder_path TEXT;",
+		// Batches are extracted in the background; earlier ones were extracted in the request.
+		"ALTER TABLE upload_batches ADD COLUMN IF NOT EXISTS status VARCHAR(50) NOT NULL DEFAULT 'extracted';",
+		"ALTER TABLE upload_batches ADD COLUMN IF NOT EXISTS error TEXT;",
+		"ALTER TABLE resumable_uploads ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'open';",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS source_url TEXT;",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_type VARCHAR(255);",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS size_bytes BIGINT;",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS content_hash VARCHAR(64);",
+		// Chunks created before the model was recorded were all embedded with text-embedding-004.
+		"ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS embedding_model VARCHAR(255) DEFAULT 'text-embedding-004';",
+		"ALTER TABLE document_chunks ADD COLUMN IF NOT EXISTS embedding_dimensions INTEGER DEFAULT 768;",
+		// The embedding column takes any dimension so a new model can become the primary one.
+		// The HNSW index has to go with the fixed dimension; it was never used, as it indexed
+		// L2 distance while retrieval orders by cosine distance. embeddingIndexQueries
+		// replaces it with cosine indexes per dimension.
+		"DROP INDEX IF EXISTS idx_document_chunks_embedding;",
+		"ALTER TABLE document_chunks ALTER COLUMN embedding TYPE vector;",
+		// Versions of a document share the ID of its first version as version_group_id.
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS version_group_id VARCHAR(255) REFERENCES documents(id) ON DELETE CASCADE;",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;",
+		"UPDATE documents SET version_group_id = id WHERE version_group_id IS NULL;",
+		"ALTER TABLE documents ALTER COLUMN version_group_id SET NOT NULL;",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS description TEXT;",
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS metadata JSONB NOT NULL DEFAULT '{}';",
+		// Existing documents stay in their uploader's personal space.
+		"ALTER TABLE documents ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(255) REFERENCES workspaces(id);",
+		// Groups are reported by OIDC providers and refreshed on every sign-in.
+		"ALTER TABLE users ADD COLUMN IF NOT EXISTS groups TEXT[] NOT NULL DEFAULT '{}';",
+		// Guests have no email; they used to get placeholder ones.
+		"ALTER TABLE users ALTER COLUMN email DROP NOT NULL;",
+		"UPDATE users SET email = NULL WHERE auth_method = 'guest' AND email = 'guest_' || id || '@example.com';",
+	}
+	for _, query := range alterQueries {
+		if _, err := DB.Exec(query); err != nil {
+			log.Fatalf("Failed to alter table with query '%s': %v", query, err)
+		}
+	}
+
+	if err := keepWorkspaceDocumentsOfDeletedUsers(); err != nil {
+		log.Fatal("Failed to change the uploader foreign key of documents:", err)
+	}
+
 	chatHistoryQuery := `
 	   CREATE TABLE IF NOT EXISTS chat_history (
 	       id VARCHAR(255) PRIMARY KEY, -- Unique ID for the chat message
@@ -84,6 +356,7 @@ func Migrate() {
 	   message_content TEXT NOT NULL,
 	   attached_documents JSONB,
 	   timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	   seq BIGSERIAL, -- Insertion order; timestamps of consecutive messages can be equal
 	   FOREIGN KEY (document_id) REFERENCES documents(id) ON DELETE CASCADE,
 	   FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
 	   );`
@@ -93,6 +366,10 @@ func Migrate() {
 		log.Fatal("Failed to create chat_history table:", err)
 	}
 
+	if err := addChatHistorySeq(); err != nil {
+		log.Fatal("Failed to add seq column to chat_history:", err)
+	}
+
 	if err := createIndexes(); err != nil {
 		log.Fatal("Failed to create indexes: ", err)
 	}
@@ -100,15 +377,108 @@ func Migrate() {
 	fmt.Println("Database migration completed")
 }
 
+// addChatHistorySeq adds the seq column to a chat_history table created without it.
+// Adding a serial column numbers the existing rows in storage order, which updates can
+// have shuffled, so they are renumbered by timestamp, ties broken by ID, and the sequence
+// moved past them. It all happens in one transaction, so the renumbering runs exactly once.
+func addChatHistorySeq() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	existsQuery := `SELECT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = 'chat_history' AND column_name = 'seq')`
+	if err := tx.QueryRow(existsQuery).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	queries := []string{
+		"ALTER TABLE chat_history ADD COLUMN seq BIGSERIAL;",
+		`UPDATE chat_history SET seq = numbered.n
+		 FROM (SELECT id, row_number() OVER (ORDER BY timestamp, id) AS n FROM chat_history) numbered
+		 WHERE chat_history.id = numbered.id;`,
+		"SELECT setval(pg_get_serial_sequence('chat_history', 'seq'), COALESCE(MAX(seq), 0) + 1, false) FROM chat_history;",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("query '%s': %w", query, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// keepWorkspaceDocumentsOfDeletedUsers changes documents.user_id, created to cascade,
+// to be set to NULL when the uploader's account is deleted, so a workspace's documents
+// outlive the members who uploaded them. Personal documents have no one else to belong to,
+// so the check constraint makes deleting a user fail unless theirs were deleted first.
+func keepWorkspaceDocumentsOfDeletedUsers() error {
+	tx, err := DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	existsQuery := `SELECT EXISTS (SELECT 1 FROM information_schema.table_constraints WHERE table_schema = current_schema() AND table_name = 'documents' AND constraint_name = 'documents_uploader_check')`
+	if err := tx.QueryRow(existsQuery).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	queries := []string{
+		"ALTER TABLE documents ALTER COLUMN user_id DROP NOT NULL;",
+		"ALTER TABLE documents DROP CONSTRAINT IF EXISTS documents_user_id_fkey;",
+		"ALTER TABLE documents ADD CONSTRAINT documents_user_id_fkey FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL;",
+		"ALTER TABLE documents ADD CONSTRAINT documents_uploader_check CHECK (user_id IS NOT NULL OR workspace_id IS NOT NULL);",
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("query '%s': %w", query, err)
+		}
+	}
+	return tx.Commit()
+}
+
 func createIndexes() error {
 	indexQueries := []string{
 		"CREATE INDEX IF NOT EXISTS idx_documents_user_id ON documents (user_id);",
 		"CREATE INDEX IF NOT EXISTS idx_document_chunks_document_id ON document_chunks (document_id);",
 		"CREATE INDEX IF NOT EXISTS idx_documents_status ON documents (status);",
-		"CREATE INDEX IF NOT EXISTS idx_document_chunks_embedding ON document_chunks USING hnsw (embedding vector_l2_ops);",
 		"CREATE INDEX IF NOT EXISTS idx_chat_history_document_user ON chat_history (document_id, user_id);",
+		"CREATE INDEX IF NOT EXISTS idx_chat_history_document_user_seq ON chat_history (document_id, user_id, seq);",
+		"CREATE INDEX IF NOT EXISTS idx_documents_parent_document_id ON documents (parent_document_id);",
+		"CREATE INDEX IF NOT EXISTS idx_documents_batch_id ON documents (batch_id);",
+		"CREATE INDEX IF NOT EXISTS idx_resumable_uploads_expires_at ON resumable_uploads (expires_at);",
+		"CREATE INDEX IF NOT EXISTS idx_documents_user_content_hash ON documents (user_id, content_hash);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_documents_version_group_version ON documents (version_group_id, version);",
+		"CREATE INDEX IF NOT EXISTS idx_documents_metadata ON documents USING gin (metadata jsonb_path_ops);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_collections_user_parent_name ON collections (user_id, COALESCE(parent_id, ''), lower(name));",
+		"CREATE INDEX IF NOT EXISTS idx_collections_parent_id ON collections (parent_id);",
+		"CREATE INDEX IF NOT EXISTS idx_document_collections_document_id ON document_collections (document_id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_name ON tags (user_id, lower(name));",
+		"CREATE INDEX IF NOT EXISTS idx_document_tags_document_id ON document_tags (document_id);",
+		"CREATE INDEX IF NOT EXISTS idx_documents_user_created_at ON documents (user_id, created_at, id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_shares_recipient_document ON shares (recipient_id, document_id) WHERE document_id IS NOT NULL;",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_shares_recipient_collection ON shares (recipient_id, collection_id) WHERE collection_id IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_shares_owner_id ON shares (owner_id);",
+		"CREATE INDEX IF NOT EXISTS idx_documents_workspace_created_at ON documents (workspace_id, created_at, id) WHERE workspace_id IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_documents_workspace_content_hash ON documents (workspace_id, content_hash) WHERE workspace_id IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members (user_id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_workspace_invitations_workspace_email ON workspace_invitations (workspace_id, lower(email));",
+		"CREATE INDEX IF NOT EXISTS idx_workspace_invitations_email ON workspace_invitations (lower(email));",
+		"CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);",
+		"CREATE INDEX IF NOT EXISTS idx_users_guest_created_at ON users (created_at) WHERE auth_method = 'guest';",
 	}
 
+	indexQueries = append(indexQueries, embeddingIndexQueries()...)
+
 	for _, query := range indexQueries {
 		_, err := DB.Exec(query)
 		if err != nil {
@@ -119,3 +489,30 @@ func createIndexes() error {
 	log.Println("Database indexes created successfully.")
 	return nil
 }
+
+// maxHNSWDimensions is the most dimensions pgvector's HNSW indexes support for vector columns.
+const maxHNSWDimensions = 2000
+
+// embeddingIndexQueries returns the HNSW indexes for cosine distance, the distance retrieval
+// orders by, over the embeddings of the configured models. The embedding columns take any
+// dimension, so each index covers one dimension through a cast; queries use it by ordering
+// by embedding::vector(n) <=> $query for rows with that dimension.
+func embeddingIndexQueries() []string {
+	var queries []string
+	seen := make(map[int64]bool)
+	for _, dims := range []int64{config.AppConfig.EmbeddingDimensions, config.AppConfig.EmbeddingNextDimensions} {
+		if seen[dims] {
+			continue
+		}
+		seen[dims] = true
+		if dims > maxHNSWDimensions {
+			log.Printf("Warning: embeddings with %d dimensions can't be indexed; similarity search scans all chunks", dims)
+			continue
+		}
+		queries = append(queries,
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_document_chunks_embedding_cosine_%[1]d ON document_chunks USING hnsw ((embedding::vector(%[1]d)) vector_cosine_ops) WHERE embedding_dimensions = %[1]d;", dims),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_chunk_embeddings_embedding_cosine_%[1]d ON chunk_embeddings USING hnsw ((embedding::vector(%[1]d)) vector_cosine_ops) WHERE dimensions = %[1]d;", dims),
+		)
+	}
+	return queries
+}
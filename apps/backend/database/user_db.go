@@ -1,13 +1,22 @@
 package database
 
 import (
+	"database/sql"
+	"errors"
+
 	"strategic-insight-analyst/backend/models"
+
+	"github.com/lib/pq"
 )
 
+// ErrEmailTaken is returned when another account, e.g. of a different sign-in method,
+// already has the email.
+var ErrEmailTaken = errors.New("email is already used by another account")
+
 func FindUserByID(uid string) (*models.User, error) {
 	var user models.User
-	query := "SELECT id, email, auth_method, created_at FROM users WHERE id = $1"
-	err := DB.QueryRow(query, uid).Scan(&user.ID, &user.Email, &user.AuthMethod, &user.CreatedAt)
+	query := "SELECT id, COALESCE(email, ''), auth_method, groups, created_at FROM users WHERE id = $1"
+	err := DB.QueryRow(query, uid).Scan(&user.ID, &user.Email, &user.AuthMethod, pq.Array(&user.Groups), &user.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
@@ -15,7 +24,33 @@ func FindUserByID(uid string) (*models.User, error) {
 }
 
 func CreateUser(user *models.User) error {
-	insertQuery := "INSERT INTO users (id, email, auth_method, created_at) VALUES ($1, $2, $3, $4)"
-	_, err := DB.Exec(insertQuery, user.ID, user.Email, user.AuthMethod, user.CreatedAt)
+	if user.Groups == nil {
+		user.Groups = []string{}
+	}
+	insertQuery := "INSERT INTO users (id, email, auth_method, groups, created_at) VALUES ($1, $2, $3, $4, $5)"
+	_, err := DB.Exec(insertQuery, user.ID, nullableEmail(user.Email), user.AuthMethod, pq.Array(user.Groups), user.CreatedAt)
+	return emailTakenError(err)
+}
+
+// UpdateUserProfile stores the profile the identity provider reports for an existing user.
+func UpdateUserProfile(user *models.User) error {
+	if user.Groups == nil {
+		user.Groups = []string{}
+	}
+	query := "UPDATE users SET email = $2, auth_method = $3, groups = $4 WHERE id = $1"
+	_, err := DB.Exec(query, user.ID, nullableEmail(user.Email), user.AuthMethod, pq.Array(user.Groups))
+	return emailTakenError(err)
+}
+
+// nullableEmail stores a missing email as NULL, which, unlike "", may occur more than once.
+func nullableEmail(email string) sql.NullString {
+	return sql.NullString{String: email, Valid: email != ""}
+}
+
+func emailTakenError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_email_key" {
+		return ErrEmailTaken
+	}
 	return err
 }
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+type apiKeyRequest struct {
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	var req apiKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	key, err := services.CreateAPIKey(userID, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		respondWithAPIKeyError(w, err, "Failed to create API key: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, key)
+}
+
+func GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	keys, err := services.GetUserAPIKeys(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve API keys: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, keys)
+}
+
+func DeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	keyID := vars["key_id"]
+
+	if err := services.RevokeAPIKey(keyID, userID); err != nil {
+		respondWithAPIKeyError(w, err, "Failed to revoke API key: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "API key revoked"})
+}
+
+func respondWithAPIKeyError(w http.ResponseWriter, err error, failure string) {
+	switch {
+	case err == sql.ErrNoRows:
+		utils.RespondWithError(w, http.StatusNotFound, "API key not found")
+	case errors.Is(err, services.ErrInvalidAPIKey):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, failure+err.Error())
+	}
+}
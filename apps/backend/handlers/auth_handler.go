@@ -1,14 +1,16 @@
 package handlers
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"slices"
 	"strategic-insight-analyst/backend/database"
-	"strategic-insight-analyst/backend/firebase"
+	"strategic-insight-analyst/backend/internal/authn"
 	"strategic-insight-analyst/backend/models"
+	"strategic-insight-analyst/backend/services"
 	"strategic-insight-analyst/backend/utils"
 	"time"
 )
@@ -24,42 +26,36 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := firebase.AuthClient.VerifyIDToken(context.Background(), req.Token)
+	token, err := authn.Verifier.VerifyIDToken(r.Context(), req.Token)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid Firebase token: "+err.Error())
+		utils.RespondWithError(w, http.StatusUnauthorized, "Invalid token: "+err.Error())
+		return
+	}
+
+	info, err := authn.Verifier.LookupUser(r.Context(), token)
+	if errors.Is(err, authn.ErrNoVerifiedEmail) {
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to look up user: "+err.Error())
 		return
 	}
 
 	user, err := database.FindUserByID(token.UID)
 	if err == sql.ErrNoRows {
-		firebaseUser, err := firebase.AuthClient.GetUser(context.Background(), token.UID)
-		if err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get user from Firebase: "+err.Error())
-			return
-		}
-
-		log.Println(firebaseUser)
-
-		authMethod := "google"
-		if firebaseUser.Email == "" {
-			authMethod = "guest"
-		}
-
 		newUser := &models.User{
 			ID:         token.UID,
-			Email:      firebaseUser.Email,
-			AuthMethod: authMethod,
+			Email:      info.Email,
+			AuthMethod: info.AuthMethod,
+			Groups:     info.Groups,
 			CreatedAt:  time.Now(),
 		}
 
-		if authMethod == "guest" {
-			newUser.Email = "guest_" + token.UID + "@example.com"
-		}
-
 		if err := database.CreateUser(newUser); err != nil {
-			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to create user in database: "+err.Error())
+			respondWithUserError(w, err, "Failed to create user in database: ")
 			return
 		}
+		newUser.ExpiresAt = services.GuestExpiresAt(newUser)
 		utils.RespondWithJSON(w, http.StatusOK, newUser)
 		return
 	} else if err != nil {
@@ -67,5 +63,46 @@ func SignupHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Keep the profile in step with the identity provider, e.g. after a change of groups.
+	// A guest who links a sign-in method to their anonymous account, which keeps its UID,
+	// is upgraded to a full account with all their data.
+	if profileChanged(user, info) {
+		if user.AuthMethod == services.AuthMethodGuest {
+			log.Printf("Upgrading guest %s to a %s account", user.ID, info.AuthMethod)
+		}
+		if info.Email != "" {
+			user.Email = info.Email
+		}
+		user.AuthMethod = info.AuthMethod
+		user.Groups = info.Groups
+		if err := database.UpdateUserProfile(user); err != nil {
+			respondWithUserError(w, err, "Failed to update user: ")
+			return
+		}
+	}
+
+	if err := services.CheckGuestExpiry(user); err != nil {
+		utils.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	user.ExpiresAt = services.GuestExpiresAt(user)
 	utils.RespondWithJSON(w, http.StatusOK, user)
 }
+
+// profileChanged reports whether the profile from the identity provider differs from the
+// stored one. A missing email doesn't clear a stored one, and a full account never turns
+// back into a guest.
+func profileChanged(user *models.User, info authn.UserInfo) bool {
+	if info.AuthMethod == services.AuthMethodGuest && user.AuthMethod != services.AuthMethodGuest {
+		return false
+	}
+	return (info.Email != "" && user.Email != info.Email) || user.AuthMethod != info.AuthMethod || !slices.Equal(user.Groups, info.Groups)
+}
+
+func respondWithUserError(w http.ResponseWriter, err error, failure string) {
+	if errors.Is(err, database.ErrEmailTaken) {
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	utils.RespondWithError(w, http.StatusInternalServerError, failure+err.Error())
+}
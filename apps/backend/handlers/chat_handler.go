@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"strategic-insight-analyst/backend/services"
@@ -18,6 +20,14 @@ type chatRequest struct {
 	DocumentID        string   `json:"document_id"`
 	UserMessage       string   `json:"message"`
 	AttachedDocuments []string `json:"attached_documents"`
+	// Version selects the version of the document to answer from; 0 means the latest.
+	Version int `json:"version,omitempty"`
+	// Filters, CollectionID and TagIDs widen retrieval to the other documents of the
+	// document's workspace or personal space whose metadata has these fields, that are in
+	// the collection and that have all the tags.
+	Filters      map[string]any `json:"filters,omitempty"`
+	CollectionID string         `json:"collection_id,omitempty"`
+	TagIDs       []string       `json:"tag_ids,omitempty"`
 }
 
 type streamData struct {
@@ -39,25 +49,38 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get attached document details
-	attachedDocs, err := services.GetDocumentsByIDs(req.AttachedDocuments, userID)
+	if len(req.Filters) > 0 {
+		if err := services.ValidateMetadataFilter(req.Filters); err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	// The conversation belongs to the document as a whole; the answer comes from one version.
+	// Access to it and the attached documents is checked once for the whole turn.
+	session, err := services.StartChat(req.DocumentID, req.Version, req.AttachedDocuments, userID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve attached documents: "+err.Error())
+		respondWithDocumentError(w, err, "Failed to retrieve document: ")
 		return
 	}
 
-	if _, err := services.SaveUserMessage(req.DocumentID, userID, req.UserMessage, attachedDocs); err != nil {
+	if _, err := session.SaveUserMessage(req.UserMessage); err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to save user message: "+err.Error())
 		return
 	}
 
-	contextText, err := services.GetRelevantContext(req.DocumentID, req.UserMessage, req.AttachedDocuments, userID)
+	contextText, err := session.RelevantContext(req.UserMessage, services.DocumentFilter{
+		WorkspaceID:  session.Document.WorkspaceID,
+		Metadata:     req.Filters,
+		CollectionID: req.CollectionID,
+		TagIDs:       req.TagIDs,
+	})
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get relevant context: "+err.Error())
 		return
 	}
 
-	history, err := services.GetChatHistoryForLLM(req.DocumentID)
+	history, err := session.HistoryForLLM()
 	if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get chat history for LLM: "+err.Error())
 		return
@@ -116,18 +139,44 @@ func ChatHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Save the successful response
-	if _, err := services.SaveAIMessage(req.DocumentID, userID, aiResponse); err != nil {
+	if _, err := session.SaveAIMessage(aiResponse); err != nil {
 		log.Printf("Failed to save AI response: %v", err)
 	}
 }
 
 func GetChatHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
 	vars := mux.Vars(r)
 	documentID := vars["document_id"]
 
-	history, err := services.GetChatHistory(documentID)
-	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve chat history: "+err.Error())
+	// Pages run backwards from the latest message; "before" and "after" take the ID of the
+	// oldest or newest message already loaded.
+	query := r.URL.Query()
+	opts := services.ChatHistoryOptions{
+		BeforeID: query.Get("before"),
+		AfterID:  query.Get("after"),
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		opts.Limit = limit
+	}
+
+	history, err := services.GetChatHistory(documentID, userID, opts)
+	if errors.Is(err, services.ErrInvalidChatCursor) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		respondWithDocumentError(w, err, "Failed to retrieve chat history: ")
 		return
 	}
 
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+type createCollectionRequest struct {
+	Name     string `json:"name"`
+	ParentID string `json:"parent_id"`
+}
+
+type updateCollectionRequest struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+func CreateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	var req createCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	collection, err := services.CreateCollection(userID, req.Name, req.ParentID)
+	if err != nil {
+		respondWithOrganizeError(w, err, "Parent collection not found", "Failed to create collection: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, collection)
+}
+
+func GetCollectionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	collections, err := services.GetUserCollections(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve collections: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, collections)
+}
+
+func GetCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	collectionID := vars["collection_id"]
+
+	collection, err := services.GetCollection(collectionID, userID)
+	if err != nil {
+		respondWithOrganizeError(w, err, "Collection not found", "Failed to retrieve collection: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, collection)
+}
+
+func UpdateCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	collectionID := vars["collection_id"]
+
+	var req updateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	collection, err := services.UpdateCollection(collectionID, userID, services.CollectionUpdate{
+		Name:     req.Name,
+		ParentID: req.ParentID,
+	})
+	if err != nil {
+		respondWithOrganizeError(w, err, "Collection not found", "Failed to update collection: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, collection)
+}
+
+func DeleteCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	collectionID := vars["collection_id"]
+
+	if err := services.DeleteCollection(collectionID, userID); err != nil {
+		respondWithOrganizeError(w, err, "Collection not found", "Failed to delete collection: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Collection deleted successfully"})
+}
+
+func AddDocumentToCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	collectionID := vars["collection_id"]
+	documentID := vars["document_id"]
+
+	if err := services.AddDocumentToCollection(collectionID, documentID, userID); err != nil {
+		respondWithOrganizeError(w, err, "Collection or document not found", "Failed to add document to collection: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Document added to collection"})
+}
+
+func RemoveDocumentFromCollectionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	collectionID := vars["collection_id"]
+	documentID := vars["document_id"]
+
+	if err := services.RemoveDocumentFromCollection(collectionID, documentID, userID); err != nil {
+		respondWithOrganizeError(w, err, "Document not found in collection", "Failed to remove document from collection: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Document removed from collection"})
+}
+
+// respondWithOrganizeError maps errors of the collection and tag services to responses.
+func respondWithOrganizeError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch {
+	case err == sql.ErrNoRows:
+		utils.RespondWithError(w, http.StatusNotFound, notFound)
+	case errors.Is(err, services.ErrDocumentNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Document not found")
+	case errors.Is(err, services.ErrPermissionDenied):
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrDuplicateName):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrInvalidCollection), errors.Is(err, services.ErrInvalidTag):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, failure+err.Error())
+	}
+}
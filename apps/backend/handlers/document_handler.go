@@ -1,9 +1,23 @@
 package handlers
 
 import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/internal/fetcher"
+	"strategic-insight-analyst/backend/internal/processor"
+	"strategic-insight-analyst/backend/internal/storage"
 	"strategic-insight-analyst/backend/services"
 	"strategic-insight-analyst/backend/utils"
 
@@ -19,28 +33,156 @@ func UploadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	userID := user.UID
 
-	if err := r.ParseMultipartForm(10 << 20); err != nil { // 10 MB
-		utils.RespondWithError(w, http.StatusBadRequest, "File size exceeds 10MB limit")
+	object, fileName, fileType, ok := receiveUpload(w, r)
+	if !ok {
 		return
 	}
 
-	file, handler, err := r.FormFile("file")
+	processStoredUpload(w, object, fileName, fileType, userID, currentWorkspaceID(r))
+}
+
+// receiveUpload streams the "file" field of a multipart upload to GCS after detecting its
+// type. On failure it writes the error response and returns false.
+func receiveUpload(w http.ResponseWriter, r *http.Request) (storage.UploadedObject, string, string, bool) {
+	maxUploadBytes := config.AppConfig.MaxUploadBytes
+	// The body limit leaves room for the multipart framing; the file itself is checked below.
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes+(1<<20))
+	reader, err := r.MultipartReader()
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Expected a multipart/form-data request")
+		return storage.UploadedObject{}, "", "", false
+	}
+
+	part, err := nextFilePart(reader)
 	if err != nil {
 		utils.RespondWithError(w, http.StatusBadRequest, "Could not retrieve file from form")
+		return storage.UploadedObject{}, "", "", false
+	}
+	defer part.Close()
+	fileName := part.FileName()
+
+	// Detect the real file type from the content instead of trusting the client's Content-Type.
+	br := bufio.NewReaderSize(part, processor.SniffLen)
+	head, err := br.Peek(processor.SniffLen)
+	if err != nil && err != io.EOF {
+		respondWithUploadError(w, err)
+		return storage.UploadedObject{}, "", "", false
+	}
+
+	fileType, err := processor.DetectFileType(head, part.Header.Get("Content-Type"), fileName)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusUnsupportedMediaType, "Invalid file type: "+err.Error()+". Supported types are "+strings.Join(processor.SupportedFileTypes, ", ")+" and application/zip")
+		return storage.UploadedObject{}, "", "", false
+	}
+
+	// The file is streamed straight to storage; nothing is buffered beyond the sniffed header.
+	object, err := storage.UploadStream(io.LimitReader(br, maxUploadBytes+1), fileName)
+	if err != nil {
+		respondWithUploadError(w, err)
+		return storage.UploadedObject{}, "", "", false
+	}
+	if object.Size > maxUploadBytes {
+		deleteUploadedObject(object)
+		respondWithUploadError(w, &http.MaxBytesError{Limit: maxUploadBytes})
+		return storage.UploadedObject{}, "", "", false
+	}
+
+	return object, fileName, fileType, true
+}
+
+// deleteUploadedObject removes an upload that won't be processed.
+func deleteUploadedObject(object storage.UploadedObject) {
+	if err := storage.DeleteFile(object.ObjectName); err != nil {
+		log.Printf("Warning: failed to delete upload %s: %v", object.ObjectName, err)
+	}
+}
+
+// processStoredUpload hands a file that has been stored in GCS to the processing pipeline,
+// fanning ZIP archives out into a batch, and writes the response. It reports whether the
+// document or batch was created; if not, the stored file has been deleted.
+func processStoredUpload(w http.ResponseWriter, object storage.UploadedObject, fileName, fileType, userID, workspaceID string) bool {
+	if processor.IsArchiveFileType(fileType) {
+		batch, err := services.ProcessZipUpload(object, fileName, userID, workspaceID)
+		if errors.Is(err, services.ErrInvalidArchive) {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return false
+		} else if err != nil {
+			// Storage and database failures end up in the default case as 500.
+			respondWithWorkspaceError(w, err, "Workspace not found", "Failed to process ZIP archive: ")
+			return false
+		}
+		utils.RespondWithJSON(w, http.StatusAccepted, batch)
+		return true
+	}
+
+	doc, err := services.ProcessAndSaveDocument(object, fileName, fileType, userID, workspaceID)
+	if err != nil {
+		deleteUploadedObject(object)
+		respondWithWorkspaceError(w, err, "Workspace not found", "Failed to process and save document: ")
+		return false
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, doc)
+	return true
+}
+
+// nextFilePart skips ahead to the "file" field of a multipart form.
+func nextFilePart(reader *multipart.Reader) (*multipart.Part, error) {
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			return nil, err
+		}
+		if part.FormName() == "file" && part.FileName() != "" {
+			return part, nil
+		}
+		part.Close()
+	}
+}
+
+func respondWithUploadError(w http.ResponseWriter, err error) {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("File size exceeds the %d MB limit", config.AppConfig.MaxUploadBytes>>20))
 		return
 	}
-	defer file.Close()
+	utils.RespondWithError(w, http.StatusInternalServerError, "Failed to upload file: "+err.Error())
+}
+
+type importURLRequest struct {
+	URL string `json:"url"`
+}
 
-	// Validate file type
-	contentType := handler.Header.Get("Content-Type")
-	if contentType != "application/pdf" && contentType != "text/plain" {
-		utils.RespondWithError(w, http.StatusUnsupportedMediaType, "Invalid file type: only application/pdf and text/plain are allowed")
+func ImportURLHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	var req importURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URL == "" {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body: a url is required")
 		return
 	}
 
-	doc, err := services.ProcessAndSaveDocument(file, handler, userID)
+	doc, err := services.ImportDocumentFromURL(req.URL, userID, currentWorkspaceID(r))
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to process and save document: "+err.Error())
+		switch {
+		case errors.Is(err, services.ErrWorkspaceNotFound), errors.Is(err, services.ErrPermissionDenied):
+			respondWithWorkspaceError(w, err, "Workspace not found", "Failed to import document: ")
+		case errors.Is(err, fetcher.ErrInvalidURL), errors.Is(err, fetcher.ErrBlockedAddress), errors.Is(err, services.ErrInvalidFileName):
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, fetcher.ErrTooLarge):
+			utils.RespondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+		case errors.Is(err, processor.ErrUnsupportedFileType), errors.Is(err, processor.ErrFileTypeMismatch):
+			utils.RespondWithError(w, http.StatusUnsupportedMediaType, err.Error())
+		case errors.Is(err, fetcher.ErrFetchFailed):
+			utils.RespondWithError(w, http.StatusBadGateway, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to import document: "+err.Error())
+		}
 		return
 	}
 
@@ -57,14 +199,84 @@ func GetDocumentsHandler(w http.ResponseWriter, r *http.Request) {
 
 	userID := user.UID
 
-	documents, err := services.GetUserDocuments(userID)
+	// Documents are listed a page at a time; pass next_cursor back as "cursor" for the next
+	// page. "metadata" holds a JSON object of fields to match, e.g.
+	// ?metadata={"company":"Acme","fiscal_year":2024}. "collection_id" narrows the list to a
+	// collection and its subcollections, and each "tag" parameter to a tag. The list is of
+	// the workspace selected with the X-Workspace-ID header, or of the personal space.
+	query := r.URL.Query()
+	opts := services.DocumentListOptions{
+		DocumentFilter: services.DocumentFilter{
+			WorkspaceID:  currentWorkspaceID(r),
+			CollectionID: query.Get("collection_id"),
+			TagIDs:       query["tag"],
+		},
+		Statuses:     query["status"],
+		ContentTypes: query["type"],
+		NameContains: query.Get("q"),
+		SortBy:       query.Get("sort"),
+		Cursor:       query.Get("cursor"),
+	}
+	if raw := query.Get("metadata"); raw != "" {
+		metadata, err := services.ParseMetadataFilter(raw)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts.Metadata = metadata
+	}
+	switch order := query.Get("order"); order {
+	case "", "desc":
+	case "asc":
+		opts.Ascending = true
+	default:
+		utils.RespondWithError(w, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		opts.Limit = limit
+	}
+	var err error
+	if opts.CreatedAfter, err = timeParam(query.Get("created_after")); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "created_after "+err.Error())
+		return
+	}
+	if opts.CreatedBefore, err = timeParam(query.Get("created_before")); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "created_before "+err.Error())
+		return
+	}
 
-	if err != nil {
+	page, err := services.GetUserDocuments(userID, opts)
+	if errors.Is(err, services.ErrInvalidListOptions) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
 		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve documents: "+err.Error())
 		return
 	}
 
-	utils.RespondWithJSON(w, http.StatusOK, documents)
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
+
+// timeParam parses an optional RFC 3339 timestamp or YYYY-MM-DD date, which stands for
+// midnight UTC.
+func timeParam(raw string) (time.Time, error) {
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, raw)
+	if err != nil {
+		return time.Time{}, errors.New("must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+	}
+	return t, nil
 }
 
 func GetDocumentStatusHandler(w http.ResponseWriter, r *http.Request) {
@@ -80,7 +292,7 @@ func GetDocumentStatusHandler(w http.ResponseWriter, r *http.Request) {
 
 	doc, err := services.GetDocumentStatus(documentID, userID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get document status: "+err.Error())
+		respondWithDocumentError(w, err, "Failed to get document status: ")
 		return
 	}
 
@@ -100,7 +312,7 @@ func DownloadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 
 	fileContent, fileName, err := services.DownloadDocument(documentID, userID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to download document: "+err.Error())
+		respondWithDocumentError(w, err, "Failed to download document: ")
 		return
 	}
 
@@ -109,6 +321,54 @@ func DownloadDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(fileContent)
 }
 
+type updateDocumentRequest struct {
+	FileName    *string        `json:"file_name"`
+	Description *string        `json:"description"`
+	Metadata    map[string]any `json:"metadata"`
+}
+
+// UpdateDocumentHandler changes the file name, description and metadata of a document.
+// Given the ID of any of its versions, it updates the latest version, the one documents are
+// listed as; earlier versions keep theirs as part of the history.
+func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	documentID := vars["document_id"]
+
+	var req updateDocumentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	doc, err := services.UpdateDocument(documentID, userID, services.DocumentUpdate{
+		FileName:    req.FileName,
+		Description: req.Description,
+		Metadata:    req.Metadata,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDocumentNotFound):
+			utils.RespondWithError(w, http.StatusNotFound, "Document not found")
+		case errors.Is(err, services.ErrPermissionDenied):
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrInvalidDocumentUpdate), errors.Is(err, services.ErrInvalidMetadata):
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to update document: "+err.Error())
+		}
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, doc)
+}
+
 func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
 	user, ok := r.Context().Value("user").(*auth.Token)
 	if !ok {
@@ -122,9 +382,45 @@ func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request) {
 
 	err := services.DeleteDocument(documentID, userID)
 	if err != nil {
-		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to delete document: "+err.Error())
+		respondWithDocumentError(w, err, "Failed to delete document: ")
 		return
 	}
 
 	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Document deleted successfully"})
 }
+
+// respondWithDocumentError responds 404 for documents the caller cannot access, and 500
+// prefixed with failure otherwise.
+func respondWithDocumentError(w http.ResponseWriter, err error, failure string) {
+	switch {
+	case errors.Is(err, services.ErrDocumentNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Document not found")
+	case errors.Is(err, services.ErrPermissionDenied):
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, failure+err.Error())
+	}
+}
+
+func GetUploadBatchHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	batchID := vars["batch_id"]
+
+	batch, err := services.GetUploadBatch(batchID, userID)
+	if err == sql.ErrNoRows {
+		utils.RespondWithError(w, http.StatusNotFound, "Upload batch not found")
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to get upload batch: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, batch)
+}
@@ -0,0 +1,12 @@
+package handlers
+
+import (
+	"net/http"
+
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+)
+
+func GetEmbeddingCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	utils.RespondWithJSON(w, http.StatusOK, services.GetEmbeddingCacheStats())
+}
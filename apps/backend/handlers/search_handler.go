@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+)
+
+func SearchHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	// The scope parameters are those of the documents list: "collection_id", repeated
+	// "tag" and a JSON "metadata" object, and the search covers the same space.
+	query := r.URL.Query()
+	opts := services.SearchOptions{
+		DocumentFilter: services.DocumentFilter{
+			WorkspaceID:  currentWorkspaceID(r),
+			CollectionID: query.Get("collection_id"),
+			TagIDs:       query["tag"],
+		},
+		Cursor: query.Get("cursor"),
+	}
+	if raw := query.Get("metadata"); raw != "" {
+		metadata, err := services.ParseMetadataFilter(raw)
+		if err != nil {
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		opts.Metadata = metadata
+	}
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			utils.RespondWithError(w, http.StatusBadRequest, "limit must be a positive integer")
+			return
+		}
+		opts.Limit = limit
+	}
+
+	page, err := services.SearchDocuments(userID, query.Get("q"), opts)
+	if errors.Is(err, services.ErrInvalidSearch) {
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	} else if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to search documents: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, page)
+}
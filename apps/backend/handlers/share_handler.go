@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+type createShareRequest struct {
+	Email        string `json:"email"`
+	DocumentID   string `json:"document_id"`
+	CollectionID string `json:"collection_id"`
+	Permission   string `json:"permission"`
+}
+
+type updateShareRequest struct {
+	Permission string `json:"permission"`
+}
+
+func CreateShareHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	var req createShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	share, err := services.ShareWith(userID, req.Email, services.ShareTarget{
+		DocumentID:   req.DocumentID,
+		CollectionID: req.CollectionID,
+	}, req.Permission)
+	if err != nil {
+		respondWithShareError(w, err, "Collection not found", "Failed to create share: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, share)
+}
+
+// GetSharesHandler lists the shares the user has granted, narrowed by the optional
+// "document_id" or "collection_id" query parameter.
+func GetSharesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	query := r.URL.Query()
+	shares, err := services.GetGrantedShares(userID, services.ShareTarget{
+		DocumentID:   query.Get("document_id"),
+		CollectionID: query.Get("collection_id"),
+	})
+	if err != nil {
+		respondWithShareError(w, err, "Share not found", "Failed to retrieve shares: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, shares)
+}
+
+func GetReceivedSharesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	shares, err := services.GetReceivedShares(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve shares: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, shares)
+}
+
+func UpdateShareHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	shareID := vars["share_id"]
+
+	var req updateShareRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	share, err := services.UpdateSharePermission(shareID, userID, req.Permission)
+	if err != nil {
+		respondWithShareError(w, err, "Share not found", "Failed to update share: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, share)
+}
+
+func DeleteShareHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	shareID := vars["share_id"]
+
+	if err := services.RevokeShare(shareID, userID); err != nil {
+		respondWithShareError(w, err, "Share not found", "Failed to delete share: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Share deleted successfully"})
+}
+
+func respondWithShareError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch {
+	case err == sql.ErrNoRows:
+		utils.RespondWithError(w, http.StatusNotFound, notFound)
+	case errors.Is(err, services.ErrDocumentNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Document not found")
+	case errors.Is(err, services.ErrRecipientNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, services.ErrPermissionDenied):
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrInvalidShare):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, failure+err.Error())
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+func CreateTagHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tag, err := services.CreateTag(userID, req.Name)
+	if err != nil {
+		respondWithOrganizeError(w, err, "Tag not found", "Failed to create tag: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, tag)
+}
+
+func GetTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	tags, err := services.GetUserTags(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve tags: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tags)
+}
+
+func UpdateTagHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	tagID := vars["tag_id"]
+
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tag, err := services.RenameTag(tagID, userID, req.Name)
+	if err != nil {
+		respondWithOrganizeError(w, err, "Tag not found", "Failed to update tag: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, tag)
+}
+
+func DeleteTagHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	tagID := vars["tag_id"]
+
+	if err := services.DeleteTag(tagID, userID); err != nil {
+		respondWithOrganizeError(w, err, "Tag not found", "Failed to delete tag: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Tag deleted successfully"})
+}
+
+func AddDocumentTagHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	documentID := vars["document_id"]
+	tagID := vars["tag_id"]
+
+	if err := services.AddTagToDocument(documentID, tagID, userID); err != nil {
+		respondWithOrganizeError(w, err, "Document or tag not found", "Failed to tag document: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Tag added to document"})
+}
+
+func RemoveDocumentTagHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	documentID := vars["document_id"]
+	tagID := vars["tag_id"]
+
+	if err := services.RemoveTagFromDocument(documentID, tagID, userID); err != nil {
+		respondWithOrganizeError(w, err, "Document does not have the tag", "Failed to remove tag from document: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Tag removed from document"})
+}
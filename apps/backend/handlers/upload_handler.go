@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"strategic-insight-analyst/backend/internal/processor"
+	"strategic-insight-analyst/backend/models"
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+// The resumable upload endpoints follow the tus protocol's conventions: the upload is
+// created with its total size, byte ranges are appended with PATCH at the offset reported
+// by HEAD, and a final POST hands the assembled file to the processing pipeline.
+
+type createUploadRequest struct {
+	FileName  string `json:"file_name"`
+	SizeBytes int64  `json:"size_bytes"`
+}
+
+func CreateResumableUploadHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	var req createUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.FileName == "" || req.SizeBytes <= 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body: file_name and a positive size_bytes are required")
+		return
+	}
+
+	upload, err := services.CreateResumableUpload(userID, req.FileName, req.SizeBytes)
+	if err != nil {
+		respondWithResumableUploadError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/uploads/"+upload.ID)
+	setUploadHeaders(w, upload)
+	utils.RespondWithJSON(w, http.StatusCreated, upload)
+}
+
+// GetResumableUploadHandler serves HEAD (offset in headers only) and GET requests.
+func GetResumableUploadHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	upload, err := services.GetResumableUpload(mux.Vars(r)["upload_id"], userID)
+	if err != nil {
+		respondWithResumableUploadError(w, err)
+		return
+	}
+
+	setUploadHeaders(w, upload)
+	w.Header().Set("Cache-Control", "no-store")
+	if r.Method == http.MethodHead {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	utils.RespondWithJSON(w, http.StatusOK, upload)
+}
+
+func PatchResumableUploadHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/offset+octet-stream") {
+		utils.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream")
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		utils.RespondWithError(w, http.StatusBadRequest, "A valid Upload-Offset header is required")
+		return
+	}
+
+	upload, err := services.AppendResumableUpload(mux.Vars(r)["upload_id"], userID, offset, r.Body)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadOffsetMismatch) {
+			setUploadHeaders(w, upload)
+		}
+		respondWithResumableUploadError(w, err)
+		return
+	}
+
+	setUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func FinalizeResumableUploadHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	object, upload, err := services.FinalizeResumableUpload(mux.Vars(r)["upload_id"], userID)
+	if err != nil {
+		respondWithResumableUploadError(w, err)
+		return
+	}
+
+	// The upload is kept until the document exists, so a failure here can be retried.
+	if !processStoredUpload(w, object, upload.FileName, upload.FileType, userID, currentWorkspaceID(r)) {
+		services.ReopenResumableUpload(upload.ID)
+		return
+	}
+	services.CompleteResumableUpload(upload.ID)
+}
+
+func DeleteResumableUploadHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	if err := services.AbortResumableUpload(mux.Vars(r)["upload_id"], userID); err != nil {
+		respondWithResumableUploadError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func setUploadHeaders(w http.ResponseWriter, upload models.ResumableUpload) {
+	if upload.ID == "" {
+		return
+	}
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.OffsetBytes, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.SizeBytes, 10))
+	w.Header().Set("Upload-Expires", upload.ExpiresAt.UTC().Format(time.RFC1123))
+}
+
+func respondWithResumableUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		utils.RespondWithError(w, http.StatusNotFound, "Upload not found or expired")
+	case errors.Is(err, services.ErrUploadOffsetMismatch), errors.Is(err, services.ErrUploadIncomplete), errors.Is(err, services.ErrUploadFinalizing):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrUploadTooLarge):
+		utils.RespondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+	case errors.Is(err, processor.ErrUnsupportedFileType), errors.Is(err, processor.ErrFileTypeMismatch):
+		utils.RespondWithError(w, http.StatusUnsupportedMediaType, "Invalid file type: "+err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, "Resumable upload failed: "+err.Error())
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"strategic-insight-analyst/backend/internal/processor"
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+func UploadDocumentVersionHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	documentID := vars["document_id"]
+
+	object, fileName, fileType, ok := receiveUpload(w, r)
+	if !ok {
+		return
+	}
+	if processor.IsArchiveFileType(fileType) {
+		deleteUploadedObject(object)
+		utils.RespondWithError(w, http.StatusUnsupportedMediaType, "A new version must be a single document, not a ZIP archive")
+		return
+	}
+
+	doc, err := services.CreateDocumentVersion(documentID, object, fileName, fileType, userID)
+	if err != nil {
+		deleteUploadedObject(object)
+		respondWithDocumentError(w, err, "Failed to save document version: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, doc)
+}
+
+func GetDocumentVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	documentID := vars["document_id"]
+
+	versions, err := services.GetDocumentVersions(documentID, userID)
+	if err != nil {
+		respondWithDocumentError(w, err, "Failed to retrieve document versions: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, versions)
+}
+
+// DiffDocumentVersionsHandler compares the versions given by the optional "from" and "to"
+// query parameters, which default to the previous and the latest version.
+func DiffDocumentVersionsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	documentID := vars["document_id"]
+
+	fromVersion, err := versionParam(r, "from")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid from version")
+		return
+	}
+	toVersion, err := versionParam(r, "to")
+	if err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid to version")
+		return
+	}
+
+	result, err := services.DiffDocumentVersions(documentID, fromVersion, toVersion, userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDocumentNotFound):
+			utils.RespondWithError(w, http.StatusNotFound, "Document version not found")
+		case errors.Is(err, services.ErrPermissionDenied):
+			utils.RespondWithError(w, http.StatusForbidden, err.Error())
+		case errors.Is(err, services.ErrInvalidVersionRange):
+			utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrVersionNotProcessed):
+			utils.RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(w, http.StatusInternalServerError, "Failed to compare document versions: "+err.Error())
+		}
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, result)
+}
+
+// versionParam parses an optional positive version number; 0 means not given.
+func versionParam(r *http.Request, name string) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version")
+	}
+	return version, nil
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"strategic-insight-analyst/backend/models"
+	"strategic-insight-analyst/backend/services"
+	"strategic-insight-analyst/backend/utils"
+
+	"firebase.google.com/go/auth"
+	"github.com/gorilla/mux"
+)
+
+type workspaceRequest struct {
+	Name string `json:"name"`
+}
+
+type invitationRequest struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type memberRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// currentWorkspaceID returns the ID of the workspace selected with the X-Workspace-ID
+// header, or "" for the personal space.
+func currentWorkspaceID(r *http.Request) string {
+	member, ok := r.Context().Value("workspace").(models.WorkspaceMember)
+	if !ok {
+		return ""
+	}
+	return member.WorkspaceID
+}
+
+func CreateWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	var req workspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	workspace, err := services.CreateWorkspace(userID, req.Name)
+	if err != nil {
+		respondWithWorkspaceError(w, err, "Workspace not found", "Failed to create workspace: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, workspace)
+}
+
+func GetWorkspacesHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	workspaces, err := services.GetUserWorkspaces(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve workspaces: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, workspaces)
+}
+
+func GetWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+
+	workspace, err := services.GetWorkspace(workspaceID, userID)
+	if err != nil {
+		respondWithWorkspaceError(w, err, "Workspace not found", "Failed to retrieve workspace: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, workspace)
+}
+
+func UpdateWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+
+	var req workspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	workspace, err := services.RenameWorkspace(workspaceID, userID, req.Name)
+	if err != nil {
+		respondWithWorkspaceError(w, err, "Workspace not found", "Failed to update workspace: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, workspace)
+}
+
+func DeleteWorkspaceHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+
+	if err := services.DeleteWorkspace(workspaceID, userID); err != nil {
+		respondWithWorkspaceError(w, err, "Workspace not found", "Failed to delete workspace: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Workspace deleted successfully"})
+}
+
+func UpdateWorkspaceMemberHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+	memberID := vars["user_id"]
+
+	var req memberRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	member, err := services.UpdateWorkspaceMemberRole(workspaceID, memberID, userID, req.Role)
+	if err != nil {
+		respondWithWorkspaceError(w, err, "Member not found", "Failed to update member: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, member)
+}
+
+// RemoveWorkspaceMemberHandler removes a member; members leave a workspace by removing
+// themselves.
+func RemoveWorkspaceMemberHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+	memberID := vars["user_id"]
+
+	if err := services.RemoveWorkspaceMember(workspaceID, memberID, userID); err != nil {
+		respondWithWorkspaceError(w, err, "Member not found", "Failed to remove member: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Member removed from workspace"})
+}
+
+func CreateWorkspaceInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+
+	var req invitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		utils.RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	invitation, err := services.InviteToWorkspace(workspaceID, userID, req.Email, req.Role)
+	if err != nil {
+		respondWithWorkspaceError(w, err, "Workspace not found", "Failed to create invitation: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusCreated, invitation)
+}
+
+func GetWorkspaceInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+
+	invitations, err := services.GetWorkspaceInvitations(workspaceID, userID)
+	if err != nil {
+		respondWithWorkspaceError(w, err, "Workspace not found", "Failed to retrieve invitations: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, invitations)
+}
+
+func DeleteWorkspaceInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	workspaceID := vars["workspace_id"]
+	invitationID := vars["invitation_id"]
+
+	if err := services.RevokeWorkspaceInvitation(workspaceID, invitationID, userID); err != nil {
+		respondWithWorkspaceError(w, err, "Invitation not found", "Failed to revoke invitation: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Invitation revoked"})
+}
+
+// GetInvitationsHandler lists the pending workspace invitations addressed to the user.
+func GetInvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	invitations, err := services.GetUserInvitations(userID)
+	if err != nil {
+		utils.RespondWithError(w, http.StatusInternalServerError, "Failed to retrieve invitations: "+err.Error())
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, invitations)
+}
+
+func AcceptInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	invitationID := vars["invitation_id"]
+
+	workspace, err := services.AcceptWorkspaceInvitation(invitationID, userID)
+	if err != nil {
+		respondWithWorkspaceError(w, err, "Invitation not found", "Failed to accept invitation: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, workspace)
+}
+
+func DeclineInvitationHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := r.Context().Value("user").(*auth.Token)
+	if !ok {
+		utils.RespondWithError(w, http.StatusUnauthorized, "User not found in context")
+		return
+	}
+	userID := user.UID
+
+	vars := mux.Vars(r)
+	invitationID := vars["invitation_id"]
+
+	if err := services.DeclineWorkspaceInvitation(invitationID, userID); err != nil {
+		respondWithWorkspaceError(w, err, "Invitation not found", "Failed to decline invitation: ")
+		return
+	}
+
+	utils.RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Invitation declined"})
+}
+
+func respondWithWorkspaceError(w http.ResponseWriter, err error, notFound, failure string) {
+	switch {
+	case err == sql.ErrNoRows:
+		utils.RespondWithError(w, http.StatusNotFound, notFound)
+	case errors.Is(err, services.ErrWorkspaceNotFound):
+		utils.RespondWithError(w, http.StatusNotFound, "Workspace not found")
+	case errors.Is(err, services.ErrPermissionDenied):
+		utils.RespondWithError(w, http.StatusForbidden, err.Error())
+	case errors.Is(err, services.ErrWorkspaceNotEmpty):
+		utils.RespondWithError(w, http.StatusConflict, err.Error())
+	case errors.Is(err, services.ErrInvalidWorkspace):
+		utils.RespondWithError(w, http.StatusBadRequest, err.Error())
+	default:
+		utils.RespondWithError(w, http.StatusInternalServerError, failure+err.Error())
+	}
+}
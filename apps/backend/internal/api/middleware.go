@@ -2,11 +2,15 @@ package api
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"strategic-insight-analyst/backend/database"
-	"strategic-insight-analyst/backend/firebase"
+	"strategic-insight-analyst/backend/internal/authn"
+	"strategic-insight-analyst/backend/services"
+
+	"firebase.google.com/go/auth"
 )
 
 func AuthMiddleware(next http.Handler) http.Handler {
@@ -18,20 +22,57 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		}
 
 		tokenString := strings.Replace(authHeader, "Bearer ", "", 1)
-		token, err := firebase.AuthClient.VerifyIDToken(context.Background(), tokenString)
-		if err != nil {
-			http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
-			return
+		var token *auth.Token
+		if strings.HasPrefix(tokenString, services.APIKeyPrefix) {
+			key, err := services.AuthenticateAPIKey(tokenString)
+			if errors.Is(err, services.ErrAPIKeyRejected) {
+				http.Error(w, "Invalid API key", http.StatusUnauthorized)
+				return
+			} else if err != nil {
+				http.Error(w, "Failed to check API key: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if scope := requiredScope(r); scope == "" || !services.APIKeyHasScope(key, scope) {
+				http.Error(w, "The API key may not be used for this request", http.StatusForbidden)
+				return
+			}
+			// Handlers only need the user's ID; they treat the key like its owner's token.
+			token = &auth.Token{UID: key.UserID, Firebase: auth.FirebaseInfo{SignInProvider: "api_key"}}
+		} else {
+			var err error
+			token, err = authn.Verifier.VerifyIDToken(r.Context(), tokenString)
+			if err != nil {
+				http.Error(w, "Invalid token: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
 		}
 
-		// Find or create user in the database
-		_, err = database.FindUserByID(token.UID)
+		// Expired guests are refused whether they send their token or one of their API keys.
+		user, err := database.FindUserByID(token.UID)
 		if err != nil {
 			http.Error(w, "Failed to find user: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if err := services.CheckGuestExpiry(user); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 		// Add user info to context
 		ctx := context.WithValue(r.Context(), "user", token)
+
+		// Requests act in the personal space unless they select a workspace the user is a
+		// member of.
+		if workspaceID := r.Header.Get("X-Workspace-ID"); workspaceID != "" {
+			member, err := services.GetWorkspaceMember(workspaceID, token.UID)
+			if errors.Is(err, services.ErrWorkspaceNotFound) {
+				http.Error(w, "Workspace not found", http.StatusNotFound)
+				return
+			} else if err != nil {
+				http.Error(w, "Failed to find workspace: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			ctx = context.WithValue(ctx, "workspace", member)
+		}
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
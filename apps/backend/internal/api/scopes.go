@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"strategic-insight-analyst/backend/services"
+
+	"github.com/gorilla/mux"
+)
+
+// uploadRoutes are the routes adding documents, which the upload scope allows.
+var uploadRoutes = map[string]bool{
+	"/api/documents/upload":                 true,
+	"/api/documents/import-url":             true,
+	"/api/uploads":                          true,
+	"/api/uploads/{upload_id}":              true,
+	"/api/uploads/{upload_id}/finalize":     true,
+	"/api/documents/{document_id}/versions": true,
+}
+
+// requiredScope returns the API key scope the request needs, or "" if API keys may not be
+// used for it at all. Keys can't manage API keys, so a leaked key can't create others.
+func requiredScope(r *http.Request) string {
+	var path string
+	if route := mux.CurrentRoute(r); route != nil {
+		path, _ = route.GetPathTemplate()
+	}
+	switch {
+	case strings.HasPrefix(path, "/api/api-keys"):
+		return ""
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		return services.ScopeRead
+	case r.Method == http.MethodDelete:
+		// Aborting an upload deletes data like any other DELETE.
+		return services.ScopeWrite
+	case uploadRoutes[path]:
+		return services.ScopeUpload
+	case path == "/api/chat":
+		return services.ScopeChat
+	default:
+		return services.ScopeWrite
+	}
+}
@@ -0,0 +1,44 @@
+package authn
+
+import (
+	"context"
+	"strings"
+
+	"firebase.google.com/go/auth"
+)
+
+// FirebaseVerifier verifies Firebase ID tokens.
+type FirebaseVerifier struct {
+	Client *auth.Client
+}
+
+// firebaseIssuerPrefix starts the issuer of Firebase ID tokens, followed by the project ID.
+const firebaseIssuerPrefix = "https://securetoken.google.com/"
+
+func (v FirebaseVerifier) AcceptsIssuer(issuer string) bool {
+	return strings.HasPrefix(issuer, firebaseIssuerPrefix)
+}
+
+func (v FirebaseVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	return v.Client.VerifyIDToken(ctx, idToken)
+}
+
+// LookupUser reads the user's profile from Firebase. The auth method is the token's sign-in
+// provider, e.g. "google.com" or "password", and "guest" for anonymous sign-ins. Users of
+// some providers, e.g. "phone", have no email. An unverified email isn't used either, as
+// invitations and shares are addressed by email and anyone can sign up with any address.
+func (v FirebaseVerifier) LookupUser(ctx context.Context, token *auth.Token) (UserInfo, error) {
+	provider := token.Firebase.SignInProvider
+	if provider == "anonymous" {
+		return UserInfo{AuthMethod: "guest"}, nil
+	}
+	user, err := v.Client.GetUser(ctx, token.UID)
+	if err != nil {
+		return UserInfo{}, err
+	}
+	info := UserInfo{AuthMethod: provider}
+	if user.EmailVerified {
+		info.Email = user.Email
+	}
+	return info, nil
+}
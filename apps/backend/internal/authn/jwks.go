@@ -0,0 +1,203 @@
+package authn
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// minJWKSRefreshInterval limits how often the keys are refetched early, for tokens signed
+// by an unknown key or after a failed fetch, so such tokens can't flood the provider.
+const minJWKSRefreshInterval = time.Minute
+
+// maxJWKSResponseBytes bounds discovery documents and key sets.
+const maxJWKSResponseBytes = 1 << 20
+
+// jwksFetchTimeout bounds a fetch of the keys. Fetches aren't tied to the request that
+// started them, as other requests may be waiting for the same fetch.
+const jwksFetchTimeout = 30 * time.Second
+
+// jwksCache holds the public keys of an OpenID Connect provider. They are refetched once
+// the TTL has passed and when a token is signed by a key that isn't known yet, which is how
+// rotated keys are picked up. Keys are fetched without holding the lock, one fetch at a
+// time, and cached keys keep being served while expired ones are refetched.
+type jwksCache struct {
+	client *http.Client
+	issuer string
+	ttl    time.Duration
+
+	mu sync.Mutex
+	// jwksURL is found through the issuer's discovery document when not configured.
+	jwksURL     string
+	keys        jose.JSONWebKeySet
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	// fetching is the fetch in progress, if any.
+	fetching *jwksFetch
+}
+
+// jwksFetch is a fetch of the keys that requests can wait for.
+type jwksFetch struct {
+	done chan struct{}
+	// err is set before done is closed.
+	err error
+}
+
+// key returns the signing key with the key ID. A token without a key ID can only be used
+// with a provider that publishes a single key.
+func (c *jwksCache) key(ctx context.Context, keyID string) (*jose.JSONWebKey, error) {
+	c.mu.Lock()
+	key := c.find(keyID)
+	var fetch *jwksFetch
+	if key == nil || time.Since(c.fetchedAt) > c.ttl {
+		fetch = c.startFetch()
+	}
+	c.mu.Unlock()
+	// A known key is used right away, even if the keys are being refetched.
+	if key != nil {
+		return key, nil
+	}
+
+	if fetch != nil {
+		select {
+		case <-fetch.done:
+			if fetch.err != nil {
+				return nil, fetch.err
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if key := c.find(keyID); key != nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("token is signed with unknown key %q", keyID)
+}
+
+// startFetch returns the fetch in progress, starting one unless the keys were fetched too
+// recently, in which case it returns nil. c.mu must be held.
+func (c *jwksCache) startFetch() *jwksFetch {
+	if c.fetching != nil {
+		return c.fetching
+	}
+	if !c.mayRefresh() {
+		return nil
+	}
+	fetch := &jwksFetch{done: make(chan struct{})}
+	c.fetching = fetch
+	c.lastAttempt = time.Now()
+	go c.run(fetch, c.jwksURL)
+	return fetch
+}
+
+func (c *jwksCache) mayRefresh() bool {
+	return time.Since(c.lastAttempt) >= minJWKSRefreshInterval
+}
+
+func (c *jwksCache) run(fetch *jwksFetch, jwksURL string) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+	keys, jwksURL, err := c.fetchKeys(ctx, jwksURL)
+
+	c.mu.Lock()
+	if err == nil {
+		c.jwksURL = jwksURL
+		c.keys = keys
+		c.fetchedAt = time.Now()
+	} else if len(c.keys.Keys) > 0 {
+		log.Printf("Warning: using cached OIDC keys of %s after a failed refresh: %v", c.issuer, err)
+	}
+	c.fetching = nil
+	c.mu.Unlock()
+
+	fetch.err = err
+	close(fetch.done)
+}
+
+func (c *jwksCache) find(keyID string) *jose.JSONWebKey {
+	var candidates []jose.JSONWebKey
+	for _, key := range c.keys.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if keyID == "" || key.KeyID == keyID {
+			candidates = append(candidates, key)
+		}
+	}
+	if len(candidates) != 1 {
+		return nil
+	}
+	return &candidates[0]
+}
+
+// fetchKeys fetches the key set, discovering its URL first if jwksURL is empty, and
+// returns it with its URL.
+func (c *jwksCache) fetchKeys(ctx context.Context, jwksURL string) (jose.JSONWebKeySet, string, error) {
+	if jwksURL == "" {
+		var err error
+		if jwksURL, err = c.discoverJWKSURL(ctx); err != nil {
+			return jose.JSONWebKeySet{}, "", err
+		}
+	}
+
+	var keys jose.JSONWebKeySet
+	if err := c.getJSON(ctx, jwksURL, &keys); err != nil {
+		return jose.JSONWebKeySet{}, "", fmt.Errorf("failed to fetch OIDC keys: %w", err)
+	}
+	// Only public keys are used; a provider publishing anything else is misconfigured.
+	for _, key := range keys.Keys {
+		if !key.IsPublic() {
+			return jose.JSONWebKeySet{}, "", fmt.Errorf("OIDC key set of %s contains a non-public key", c.issuer)
+		}
+	}
+	return keys, jwksURL, nil
+}
+
+// discoverJWKSURL reads the key set's URL from the issuer's OpenID Connect discovery
+// document.
+func (c *jwksCache) discoverJWKSURL(ctx context.Context) (string, error) {
+	var discovery struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+	discoveryURL := strings.TrimSuffix(c.issuer, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, discoveryURL, &discovery); err != nil {
+		return "", fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	if discovery.Issuer != c.issuer {
+		return "", fmt.Errorf("OIDC discovery document is for issuer %q, not %q", discovery.Issuer, c.issuer)
+	}
+	if discovery.JWKSURI == "" {
+		return "", fmt.Errorf("OIDC discovery document of %s has no jwks_uri", c.issuer)
+	}
+	return discovery.JWKSURI, nil
+}
+
+func (c *jwksCache) getJSON(ctx context.Context, url string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxJWKSResponseBytes)).Decode(dest)
+}
@@ -0,0 +1,242 @@
+package authn
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"strategic-insight-analyst/backend/config"
+
+	"firebase.google.com/go/auth"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// minSecretLength is the shortest HS256 secret accepted, the length of the hash.
+const minSecretLength = 32
+
+// clockSkew is how far the clocks of the minting and verifying machines may differ.
+const clockSkew = time.Minute
+
+// LocalKeys sign and verify the tokens of the local mode: either an HS256 secret or an
+// RS256 key pair, of which verifying needs only the public key.
+type LocalKeys struct {
+	Secret     []byte
+	PublicKey  *rsa.PublicKey
+	PrivateKey *rsa.PrivateKey
+}
+
+// LoadLocalKeys reads the local mode's keys from the configuration.
+func LoadLocalKeys(cfg *config.Config) (LocalKeys, error) {
+	var keys LocalKeys
+	if cfg.LocalJWTSecret != "" {
+		keys.Secret = []byte(cfg.LocalJWTSecret)
+	}
+	if cfg.LocalJWTPrivateKeyFile != "" {
+		key, err := readPrivateKey(cfg.LocalJWTPrivateKeyFile)
+		if err != nil {
+			return LocalKeys{}, err
+		}
+		keys.PrivateKey = key
+		keys.PublicKey = &key.PublicKey
+	}
+	if cfg.LocalJWTPublicKeyFile != "" {
+		key, err := readPublicKey(cfg.LocalJWTPublicKeyFile)
+		if err != nil {
+			return LocalKeys{}, err
+		}
+		if keys.PrivateKey != nil && !keys.PrivateKey.PublicKey.Equal(key) {
+			return LocalKeys{}, errors.New("LOCAL_JWT_PUBLIC_KEY_FILE doesn't match LOCAL_JWT_PRIVATE_KEY_FILE")
+		}
+		keys.PublicKey = key
+	}
+
+	switch {
+	case keys.Secret == nil && keys.PublicKey == nil:
+		return LocalKeys{}, errors.New("AUTH_MODE=local needs LOCAL_JWT_SECRET or an RS256 key file")
+	case keys.Secret != nil && keys.PublicKey != nil:
+		return LocalKeys{}, errors.New("set either LOCAL_JWT_SECRET or RS256 key files, not both")
+	case keys.Secret != nil && len(keys.Secret) < minSecretLength:
+		return LocalKeys{}, fmt.Errorf("LOCAL_JWT_SECRET must be at least %d bytes", minSecretLength)
+	}
+	return keys, nil
+}
+
+func readPEM(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s is not a PEM file", path)
+	}
+	return block.Bytes, nil
+}
+
+// readPublicKey reads a PKIX ("PUBLIC KEY") or PKCS #1 ("RSA PUBLIC KEY") RSA key.
+func readPublicKey(path string) (*rsa.PublicKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA public key", path)
+	}
+	return key, nil
+}
+
+// readPrivateKey reads a PKCS #8 ("PRIVATE KEY") or PKCS #1 ("RSA PRIVATE KEY") RSA key.
+func readPrivateKey(path string) (*rsa.PrivateKey, error) {
+	der, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s is not an RSA private key", path)
+	}
+	return key, nil
+}
+
+// algorithm is the only signature algorithm tokens are accepted with, so a token can't
+// pick a weaker one.
+func (k LocalKeys) algorithm() jose.SignatureAlgorithm {
+	if k.Secret != nil {
+		return jose.HS256
+	}
+	return jose.RS256
+}
+
+func (k LocalKeys) verificationKey() any {
+	if k.Secret != nil {
+		return k.Secret
+	}
+	return k.PublicKey
+}
+
+// localClaims are the claims of local tokens besides the registered ones.
+type localClaims struct {
+	Email string `json:"email,omitempty"`
+}
+
+// LocalVerifier verifies the JWTs of the local mode. The subject is the user's ID and the
+// optional "email" claim their email; tokens without one identify guests.
+type LocalVerifier struct {
+	keys     LocalKeys
+	issuer   string
+	audience string
+}
+
+func NewLocalVerifier(keys LocalKeys, issuer, audience string) (*LocalVerifier, error) {
+	if issuer == "" || audience == "" {
+		return nil, errors.New("the local mode needs an issuer and an audience")
+	}
+	return &LocalVerifier{keys: keys, issuer: issuer, audience: audience}, nil
+}
+
+func (v *LocalVerifier) AcceptsIssuer(issuer string) bool {
+	return issuer == v.issuer
+}
+
+func (v *LocalVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	parsed, err := jwt.ParseSigned(idToken, []jose.SignatureAlgorithm{v.keys.algorithm()})
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	var registered jwt.Claims
+	var custom localClaims
+	var all map[string]any
+	if err := parsed.Claims(v.keys.verificationKey(), &registered, &custom, &all); err != nil {
+		return nil, fmt.Errorf("invalid token signature: %w", err)
+	}
+
+	expected := jwt.Expected{Issuer: v.issuer, AnyAudience: jwt.Audience{v.audience}, Time: time.Now()}
+	if err := registered.ValidateWithLeeway(expected, clockSkew); err != nil {
+		return nil, err
+	}
+	if registered.Subject == "" {
+		return nil, errors.New("token has no subject")
+	}
+	if registered.Expiry == nil {
+		return nil, errors.New("token has no expiry")
+	}
+
+	signInProvider := "custom"
+	if custom.Email == "" {
+		signInProvider = "anonymous"
+	}
+	token := &auth.Token{
+		Issuer:   registered.Issuer,
+		Audience: v.audience,
+		Expires:  registered.Expiry.Time().Unix(),
+		Subject:  registered.Subject,
+		UID:      registered.Subject,
+		Firebase: auth.FirebaseInfo{SignInProvider: signInProvider},
+		Claims:   all,
+	}
+	if registered.IssuedAt != nil {
+		token.IssuedAt = registered.IssuedAt.Time().Unix()
+		token.AuthTime = token.IssuedAt
+	}
+	return token, nil
+}
+
+// LookupUser reads the profile from the token's claims; there is no user directory.
+func (v *LocalVerifier) LookupUser(ctx context.Context, token *auth.Token) (UserInfo, error) {
+	email, _ := token.Claims["email"].(string)
+	if email == "" {
+		return UserInfo{AuthMethod: "guest"}, nil
+	}
+	return UserInfo{Email: email, AuthMethod: "local"}, nil
+}
+
+// MintLocalToken signs a token of the local mode for the user with the ID uid, valid for
+// ttl. Without an email the user is a guest. Minting with an RS256 key pair needs the
+// private key.
+func MintLocalToken(keys LocalKeys, issuer, audience, uid, email string, ttl time.Duration) (string, error) {
+	if uid == "" {
+		return "", errors.New("a user ID is required")
+	}
+	signingKey := jose.SigningKey{Algorithm: keys.algorithm(), Key: keys.Secret}
+	if keys.Secret == nil {
+		if keys.PrivateKey == nil {
+			return "", errors.New("minting RS256 tokens needs LOCAL_JWT_PRIVATE_KEY_FILE")
+		}
+		signingKey.Key = keys.PrivateKey
+	}
+	signer, err := jose.NewSigner(signingKey, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	registered := jwt.Claims{
+		Issuer:   issuer,
+		Audience: jwt.Audience{audience},
+		Subject:  uid,
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(ttl)),
+	}
+	return jwt.Signed(signer).Claims(registered).Claims(localClaims{Email: email}).Serialize()
+}
@@ -0,0 +1,167 @@
+package authn
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"strategic-insight-analyst/backend/config"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	testIssuer = "local-test"
+	testSecret = "0123456789abcdef0123456789abcdef"
+)
+
+// signHS256 signs arbitrary claims, for tokens MintLocalToken refuses to create.
+func signHS256(t *testing.T, secret string, claims map[string]any) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: []byte(secret)}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func localClaimsWithout(name string) map[string]any {
+	claims := map[string]any{
+		"iss": testIssuer,
+		"aud": testAudience,
+		"sub": "user-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	delete(claims, name)
+	return claims
+}
+
+func TestLocalVerifier(t *testing.T) {
+	keys := LocalKeys{Secret: []byte(testSecret)}
+	rsaKey := newTestKey(t, "")
+	rsaKeys := LocalKeys{PrivateKey: rsaKey.private, PublicKey: &rsaKey.private.PublicKey}
+
+	mint := func(keys LocalKeys, issuer, audience string, ttl time.Duration) string {
+		token, err := MintLocalToken(keys, issuer, audience, "user-1", "user@example.com", ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tests := []struct {
+		name    string
+		keys    LocalKeys
+		token   string
+		wantErr bool
+	}{
+		{"valid HS256", keys, mint(keys, testIssuer, testAudience, time.Hour), false},
+		{"valid RS256", rsaKeys, mint(rsaKeys, testIssuer, testAudience, time.Hour), false},
+		{"wrong secret", keys, mint(LocalKeys{Secret: []byte(strings.Repeat("x", minSecretLength))}, testIssuer, testAudience, time.Hour), true},
+		{"RS256 token for an HS256 verifier", keys, mint(rsaKeys, testIssuer, testAudience, time.Hour), true},
+		{"HS256 token for an RS256 verifier", rsaKeys, mint(keys, testIssuer, testAudience, time.Hour), true},
+		{"alg none", keys, unsignedToken(t, "", localClaimsWithout("")), true},
+		{"wrong issuer", keys, mint(keys, "other", testAudience, time.Hour), true},
+		{"wrong audience", keys, mint(keys, testIssuer, "other", time.Hour), true},
+		{"expired", keys, mint(keys, testIssuer, testAudience, -time.Hour), true},
+		{"no expiry", keys, signHS256(t, testSecret, localClaimsWithout("exp")), true},
+		{"no subject", keys, signHS256(t, testSecret, localClaimsWithout("sub")), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := NewLocalVerifier(tt.keys, testIssuer, testAudience)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token, err := v.VerifyIDToken(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyIDToken() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && token.UID != "user-1" {
+				t.Errorf("UID = %q, want %q", token.UID, "user-1")
+			}
+		})
+	}
+}
+
+func TestLocalLookupUser(t *testing.T) {
+	keys := LocalKeys{Secret: []byte(testSecret)}
+	v, err := NewLocalVerifier(keys, testIssuer, testAudience)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		email          string
+		wantAuthMethod string
+	}{
+		{"user@example.com", "local"},
+		{"", "guest"},
+	}
+	for _, tt := range tests {
+		minted, err := MintLocalToken(keys, testIssuer, testAudience, "user-1", tt.email, time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := v.VerifyIDToken(context.Background(), minted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		info, err := v.LookupUser(context.Background(), token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Email != tt.email || info.AuthMethod != tt.wantAuthMethod {
+			t.Errorf("LookupUser() = %+v, want email %q and auth method %q", info, tt.email, tt.wantAuthMethod)
+		}
+	}
+}
+
+func TestMintLocalToken(t *testing.T) {
+	rsaKey := newTestKey(t, "")
+	tests := []struct {
+		name string
+		keys LocalKeys
+		uid  string
+	}{
+		{"no user ID", LocalKeys{Secret: []byte(testSecret)}, ""},
+		{"RS256 without the private key", LocalKeys{PublicKey: &rsaKey.private.PublicKey}, "user-1"},
+	}
+	for _, tt := range tests {
+		if _, err := MintLocalToken(tt.keys, testIssuer, testAudience, tt.uid, "", time.Hour); err == nil {
+			t.Errorf("%s: MintLocalToken() succeeded", tt.name)
+		}
+	}
+}
+
+func TestLoadLocalKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		wantErr bool
+	}{
+		{"secret", config.Config{LocalJWTSecret: testSecret}, false},
+		{"short secret", config.Config{LocalJWTSecret: testSecret[:minSecretLength-1]}, true},
+		{"no keys", config.Config{}, true},
+		{"missing key file", config.Config{LocalJWTPublicKeyFile: "/nonexistent.pem"}, true},
+	}
+	for _, tt := range tests {
+		if _, err := LoadLocalKeys(&tt.cfg); (err != nil) != tt.wantErr {
+			t.Errorf("%s: LoadLocalKeys() error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNewLocalVerifier(t *testing.T) {
+	keys := LocalKeys{Secret: []byte(testSecret)}
+	if _, err := NewLocalVerifier(keys, "", testAudience); err == nil {
+		t.Error("NewLocalVerifier() accepted an empty issuer")
+	}
+	if _, err := NewLocalVerifier(keys, testIssuer, ""); err == nil {
+		t.Error("NewLocalVerifier() accepted an empty audience")
+	}
+}
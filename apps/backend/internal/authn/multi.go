@@ -0,0 +1,58 @@
+package authn
+
+import (
+	"context"
+	"fmt"
+
+	"firebase.google.com/go/auth"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// anyAlgorithm lists the signature algorithms of all modes, for reading a token's issuer
+// before it is verified.
+var anyAlgorithm = []jose.SignatureAlgorithm{
+	jose.HS256, jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512, jose.ES256, jose.ES384, jose.ES512,
+}
+
+// MultiVerifier hands each token to the first of its verifiers accepting the token's
+// issuer, which then verifies it completely.
+type MultiVerifier []TokenVerifier
+
+func (m MultiVerifier) AcceptsIssuer(issuer string) bool {
+	return m.forIssuer(issuer) != nil
+}
+
+func (m MultiVerifier) VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error) {
+	parsed, err := jwt.ParseSigned(idToken, anyAlgorithm)
+	if err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+	var claims jwt.Claims
+	if err := parsed.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil, fmt.Errorf("malformed token: %w", err)
+	}
+
+	verifier := m.forIssuer(claims.Issuer)
+	if verifier == nil {
+		return nil, fmt.Errorf("tokens of issuer %q are not accepted", claims.Issuer)
+	}
+	return verifier.VerifyIDToken(ctx, idToken)
+}
+
+func (m MultiVerifier) LookupUser(ctx context.Context, token *auth.Token) (UserInfo, error) {
+	verifier := m.forIssuer(token.Issuer)
+	if verifier == nil {
+		return UserInfo{}, fmt.Errorf("tokens of issuer %q are not accepted", token.Issuer)
+	}
+	return verifier.LookupUser(ctx, token)
+}
+
+func (m MultiVerifier) forIssuer(issuer string) TokenVerifier {
+	for _, verifier := range m {
+		if verifier.AcceptsIssuer(issuer) {
+			return verifier
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package authn
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/auth"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const testAudience = "test-client"
+
+type testKey struct {
+	id      string
+	private *rsa.PrivateKey
+}
+
+func newTestKey(t *testing.T, id string) testKey {
+	t.Helper()
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testKey{id: id, private: private}
+}
+
+// testProvider is an OpenID Connect provider publishing a discovery document and keys.
+type testProvider struct {
+	server *httptest.Server
+
+	mu   sync.Mutex
+	keys []testKey
+	// block, if set, holds key set requests until it is closed.
+	block chan struct{}
+}
+
+func newTestProvider(t *testing.T, keys ...testKey) *testProvider {
+	p := &testProvider{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": p.server.URL, "jwks_uri": p.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		block := p.block
+		var set jose.JSONWebKeySet
+		for _, key := range p.keys {
+			set.Keys = append(set.Keys, jose.JSONWebKey{Key: &key.private.PublicKey, KeyID: key.id, Algorithm: string(jose.RS256), Use: "sig"})
+		}
+		p.mu.Unlock()
+		if block != nil {
+			<-block
+		}
+		json.NewEncoder(w).Encode(set)
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProvider) setKeys(keys ...testKey) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys = keys
+}
+
+func (p *testProvider) verifier(t *testing.T) *OIDCVerifier {
+	t.Helper()
+	v, err := NewOIDCVerifier(OIDCConfig{IssuerURL: p.server.URL, Audience: testAudience, HTTPClient: p.server.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// allowRefresh lets the verifier refetch the keys right away, as if the minimum refresh
+// interval had passed.
+func allowRefresh(v *OIDCVerifier) {
+	v.keys.mu.Lock()
+	defer v.keys.mu.Unlock()
+	v.keys.lastAttempt = time.Time{}
+}
+
+func testClaims(issuer string) map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":            issuer,
+		"aud":            testAudience,
+		"sub":            "user-1",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+}
+
+func signToken(t *testing.T, key testKey, claims map[string]any) string {
+	t.Helper()
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key.private, KeyID: key.id}},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// unsignedToken returns a token with the "none" algorithm.
+func unsignedToken(t *testing.T, kid string, claims map[string]any) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "none", "typ": "JWT", "kid": kid})
+	payload, _ := json.Marshal(claims)
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+// hmacToken returns a token signed with HS256 using the public key as the secret, the
+// classic confusion of asymmetric and symmetric algorithms.
+func hmacToken(t *testing.T, key testKey, claims map[string]any) string {
+	t.Helper()
+	secret, err := x509.MarshalPKIXPublicKey(&key.private.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.HS256, Key: jose.JSONWebKey{Key: secret, KeyID: key.id}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	unpublished := newTestKey(t, "key-2")
+	p := newTestProvider(t, key)
+	issuer := p.server.URL
+
+	with := func(name string, value any) map[string]any {
+		claims := testClaims(issuer)
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", signToken(t, key, testClaims(issuer)), false},
+		{"unknown key", signToken(t, unpublished, testClaims(issuer)), true},
+		{"alg none", unsignedToken(t, key.id, testClaims(issuer)), true},
+		{"alg HS256", hmacToken(t, key, testClaims(issuer)), true},
+		{"wrong issuer", signToken(t, key, with("iss", "https://evil.example.com")), true},
+		{"wrong audience", signToken(t, key, with("aud", "other-client")), true},
+		{"expired", signToken(t, key, with("exp", time.Now().Add(-time.Hour).Unix())), true},
+		{"no expiry", signToken(t, key, with("exp", nil)), true},
+		{"no subject", signToken(t, key, with("sub", nil)), true},
+	}
+	v := p.verifier(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := v.VerifyIDToken(context.Background(), tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyIDToken() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && token.UID != oidcUIDPrefix+"user-1" {
+				t.Errorf("UID = %q, want %q", token.UID, oidcUIDPrefix+"user-1")
+			}
+		})
+	}
+}
+
+func TestOIDCKeyRotation(t *testing.T) {
+	oldKey := newTestKey(t, "old")
+	newKey := newTestKey(t, "new")
+	p := newTestProvider(t, oldKey)
+	v := p.verifier(t)
+	ctx := context.Background()
+
+	if _, err := v.VerifyIDToken(ctx, signToken(t, oldKey, testClaims(p.server.URL))); err != nil {
+		t.Fatalf("token of the old key: %v", err)
+	}
+
+	p.setKeys(newKey)
+	allowRefresh(v)
+	if _, err := v.VerifyIDToken(ctx, signToken(t, newKey, testClaims(p.server.URL))); err != nil {
+		t.Fatalf("token of the rotated key: %v", err)
+	}
+	if _, err := v.VerifyIDToken(ctx, signToken(t, oldKey, testClaims(p.server.URL))); err == nil {
+		t.Fatal("token of the retired key was accepted")
+	}
+}
+
+func TestOIDCServesCachedKeysWhileRefreshing(t *testing.T) {
+	key := newTestKey(t, "key-1")
+	p := newTestProvider(t, key)
+	v := p.verifier(t)
+	token := signToken(t, key, testClaims(p.server.URL))
+
+	if _, err := v.VerifyIDToken(context.Background(), token); err != nil {
+		t.Fatal(err)
+	}
+
+	// Expire the keys and hold the refetch.
+	block := make(chan struct{})
+	defer close(block)
+	p.mu.Lock()
+	p.block = block
+	p.mu.Unlock()
+	v.keys.mu.Lock()
+	v.keys.fetchedAt = time.Time{}
+	v.keys.lastAttempt = time.Time{}
+	v.keys.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := v.VerifyIDToken(ctx, token); err != nil {
+		t.Fatalf("token wasn't verified with the cached keys during a refresh: %v", err)
+	}
+}
+
+func TestOIDCLookupUserEmailVerified(t *testing.T) {
+	p := newTestProvider(t)
+	strict := p.verifier(t)
+	// lenient trusts tokens without the claim, as configured for Azure AD.
+	lenient, err := NewOIDCVerifier(OIDCConfig{IssuerURL: p.server.URL, Audience: testAudience, EmailVerifiedOptional: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name      string
+		verifier  *OIDCVerifier
+		verified  any
+		wantEmail string
+		wantErr   error
+	}{
+		{"verified", strict, true, "user@example.com", nil},
+		{"unverified", strict, false, "", ErrNoVerifiedEmail},
+		{"claim missing", strict, nil, "", ErrNoVerifiedEmail},
+		{"claim not a boolean", strict, "true", "", ErrNoVerifiedEmail},
+		{"claim missing, optional", lenient, nil, "user@example.com", nil},
+		{"unverified, optional", lenient, false, "", ErrNoVerifiedEmail},
+		{"claim not a boolean, optional", lenient, "false", "", ErrNoVerifiedEmail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := map[string]any{"email": "user@example.com"}
+			if tt.verified != nil {
+				claims["email_verified"] = tt.verified
+			}
+			info, err := tt.verifier.LookupUser(context.Background(), &auth.Token{Claims: claims})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LookupUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if info.Email != tt.wantEmail {
+				t.Errorf("Email = %q, want %q", info.Email, tt.wantEmail)
+			}
+		})
+	}
+}
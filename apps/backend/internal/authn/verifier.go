@@ -0,0 +1,93 @@
+// Package authn verifies the ID tokens clients authenticate with.
+package authn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/firebase"
+
+	"firebase.google.com/go/auth"
+)
+
+// ErrNoVerifiedEmail is returned by LookupUser when the identity provider doesn't vouch for
+// an email of a user who isn't a guest.
+var ErrNoVerifiedEmail = errors.New("the identity provider didn't provide a verified email")
+
+// TokenVerifier verifies ID tokens and looks up the users they identify. Tokens are returned
+// as Firebase tokens whatever their issuer, so handlers read the caller from the request
+// context the same way in every mode.
+type TokenVerifier interface {
+	VerifyIDToken(ctx context.Context, idToken string) (*auth.Token, error)
+	// LookupUser returns the profile of the verified token's user.
+	LookupUser(ctx context.Context, token *auth.Token) (UserInfo, error)
+	// AcceptsIssuer reports whether the verifier is the one for tokens with the "iss" claim.
+	AcceptsIssuer(issuer string) bool
+}
+
+// UserInfo is the profile a user is registered with.
+type UserInfo struct {
+	// Email is empty for guests.
+	Email string
+	// AuthMethod is how the user signs in, e.g. "google.com", "oidc" or "guest".
+	AuthMethod string
+	// Groups are the user's groups at the identity provider, if it has any.
+	Groups []string
+}
+
+// Verifier is the verifier for the configured AUTH_MODE.
+var Verifier TokenVerifier
+
+// Initialize sets Verifier for the configured AUTH_MODE. With several modes, tokens are
+// verified by the mode trusting their issuer.
+func Initialize() error {
+	var verifiers []TokenVerifier
+	for _, mode := range config.AppConfig.AuthModes {
+		verifier, err := newVerifier(mode)
+		if err != nil {
+			return fmt.Errorf("failed to set up %s authentication: %w", mode, err)
+		}
+		verifiers = append(verifiers, verifier)
+	}
+
+	switch len(verifiers) {
+	case 0:
+		return errors.New("AUTH_MODE names no authentication mode")
+	case 1:
+		Verifier = verifiers[0]
+	default:
+		Verifier = MultiVerifier(verifiers)
+	}
+	return nil
+}
+
+func newVerifier(mode string) (TokenVerifier, error) {
+	cfg := config.AppConfig
+	switch mode {
+	case "firebase":
+		firebase.Initialize()
+		return FirebaseVerifier{Client: firebase.AuthClient}, nil
+	case "oidc":
+		return NewOIDCVerifier(OIDCConfig{
+			IssuerURL:             cfg.OIDCIssuerURL,
+			Audience:              cfg.OIDCAudience,
+			JWKSURL:               cfg.OIDCJWKSURL,
+			CacheTTL:              cfg.OIDCJWKSCacheTTL,
+			EmailClaim:            cfg.OIDCEmailClaim,
+			GroupsClaim:           cfg.OIDCGroupsClaim,
+			EmailVerifiedOptional: !cfg.OIDCRequireEmailVerified,
+		})
+	case "local":
+		keys, err := LoadLocalKeys(cfg)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("Warning: AUTH_MODE=local accepts locally signed tokens; don't use it in production.")
+		return NewLocalVerifier(keys, cfg.LocalJWTIssuer, cfg.LocalJWTAudience)
+	default:
+		return nil, fmt.Errorf("unknown AUTH_MODE %q", mode)
+	}
+}
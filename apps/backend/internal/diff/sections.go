@@ -0,0 +1,125 @@
+package diff
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Change types reported by Sections.
+const (
+	Added    = "added"
+	Removed  = "removed"
+	Modified = "modified"
+)
+
+// maxLCSCells bounds the size of the LCS table. Larger middles, left after trimming the
+// common prefix and suffix, are reported as one block of removals and additions.
+const maxLCSCells = 4_000_000
+
+var (
+	sectionBreakRegex = regexp.MustCompile(`\n[ \t\f]*\n`)
+	spaceRegex        = regexp.MustCompile(`[ \t\f\r]+`)
+)
+
+// Change is a section that differs between two versions of a text. Before is empty for
+// added sections and After for removed ones.
+type Change struct {
+	Type   string `json:"type"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// Result is the section-level difference between two texts.
+type Result struct {
+	Changes   []Change `json:"changes"`
+	Unchanged int      `json:"unchanged_sections"`
+}
+
+// SplitSections splits text into sections at blank lines, normalising the whitespace
+// within each section so layout differences don't show up as changes.
+func SplitSections(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	var sections []string
+	for _, block := range sectionBreakRegex.Split(text, -1) {
+		lines := strings.Split(block, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimSpace(spaceRegex.ReplaceAllString(line, " "))
+		}
+		if section := strings.TrimSpace(strings.Join(lines, "\n")); section != "" {
+			sections = append(sections, section)
+		}
+	}
+	return sections
+}
+
+// Sections compares the sections of before and after. Runs of removed sections directly
+// followed by added ones are paired up as modifications.
+func Sections(before, after string) Result {
+	a, b := SplitSections(before), SplitSections(after)
+
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	result := Result{Changes: []Change{}, Unchanged: prefix + suffix}
+	var removed, added []string
+	flush := func() {
+		paired := min(len(removed), len(added))
+		for i := 0; i < paired; i++ {
+			result.Changes = append(result.Changes, Change{Type: Modified, Before: removed[i], After: added[i]})
+		}
+		for _, s := range removed[paired:] {
+			result.Changes = append(result.Changes, Change{Type: Removed, Before: s})
+		}
+		for _, s := range added[paired:] {
+			result.Changes = append(result.Changes, Change{Type: Added, After: s})
+		}
+		removed, added = nil, nil
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if len(midA)*len(midB) > maxLCSCells {
+		removed, added = midA, midB
+		flush()
+		return result
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of midA[i:] and midB[j:].
+	lcs := make([][]int32, len(midA)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(midB)+1)
+	}
+	for i := len(midA) - 1; i >= 0; i-- {
+		for j := len(midB) - 1; j >= 0; j-- {
+			if midA[i] == midB[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(midA) || j < len(midB) {
+		switch {
+		case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+			flush()
+			result.Unchanged++
+			i++
+			j++
+		case j == len(midB) || (i < len(midA) && lcs[i+1][j] >= lcs[i][j+1]):
+			removed = append(removed, midA[i])
+			i++
+		default:
+			added = append(added, midB[j])
+			j++
+		}
+	}
+	flush()
+	return result
+}
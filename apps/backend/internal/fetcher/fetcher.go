@@ -0,0 +1,193 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"path"
+	"syscall"
+	"time"
+)
+
+var (
+	ErrInvalidURL     = errors.New("invalid URL")
+	ErrBlockedAddress = errors.New("URL resolves to a private or reserved address")
+	ErrTooLarge       = errors.New("remote file exceeds the maximum import size")
+	ErrFetchFailed    = errors.New("failed to fetch URL")
+)
+
+const maxRedirects = 5
+
+// reservedPrefixes are blocked in addition to the ranges netip classifies as
+// private, loopback, link-local or multicast.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),      // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),  // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),   // IETF protocol assignments
+	netip.MustParsePrefix("198.18.0.0/15"),  // benchmarking
+	netip.MustParsePrefix("240.0.0.0/4"),    // reserved
+	netip.MustParsePrefix("64:ff9b:1::/48"), // local-use NAT64, with no fixed IPv4 position
+	netip.MustParsePrefix("2001:db8::/32"),  // documentation
+}
+
+// Prefixes of IPv6 addresses embedding an IPv4 address, which can reach private IPv4
+// networks through a translator or relay. The embedded address is checked as well.
+var (
+	nat64Prefix  = netip.MustParsePrefix("64:ff9b::/96") // well-known NAT64, IPv4 in the last 4 bytes
+	sixToFour    = netip.MustParsePrefix("2002::/16")    // 6to4, IPv4 in bytes 2-5
+	teredoPrefix = netip.MustParsePrefix("2001::/32")    // Teredo, server IPv4 in bytes 4-7, client in 12-15 inverted
+)
+
+// Result is a successfully fetched remote file.
+type Result struct {
+	Data        []byte
+	ContentType string
+	FileName    string
+	FinalURL    string
+}
+
+// Fetch downloads rawURL, refusing to connect to private, loopback, link-local and other
+// reserved addresses. The check runs on the resolved IP at connect time, so it also covers
+// redirects and DNS rebinding.
+func Fetch(rawURL string, maxBytes int64, timeout time.Duration) (*Result, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: only absolute http and https URLs are supported", ErrInvalidURL)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("%w: URLs with credentials are not supported", ErrInvalidURL)
+	}
+
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			return checkAddress(address)
+		},
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			// No proxy: the dial check has to see the real destination.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: timeout,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("%w: redirect to unsupported scheme %q", ErrInvalidURL, req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidURL, err)
+	}
+	req.Header.Set("User-Agent", "StrategicInsightAnalyst/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("%w: remote server returned %s", ErrFetchFailed, resp.Status)
+	}
+	if resp.ContentLength > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	if int64(len(data)) > maxBytes {
+		return nil, ErrTooLarge
+	}
+
+	return &Result{
+		Data:        data,
+		ContentType: resp.Header.Get("Content-Type"),
+		FileName:    fileName(resp),
+		FinalURL:    resp.Request.URL.String(),
+	}, nil
+}
+
+func checkAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, host)
+	}
+	return checkIP(addr.Unmap())
+}
+
+func checkIP(addr netip.Addr) error {
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, addr)
+		}
+	}
+	for _, embedded := range embeddedIPv4(addr) {
+		if err := checkIP(embedded); err != nil {
+			return fmt.Errorf("%w: %s embeds %s", ErrBlockedAddress, addr, embedded)
+		}
+	}
+	return nil
+}
+
+// embeddedIPv4 returns the IPv4 addresses embedded in a NAT64, 6to4 or Teredo address.
+func embeddedIPv4(addr netip.Addr) []netip.Addr {
+	b := addr.As16()
+	switch {
+	case !addr.Is6():
+		return nil
+	case nat64Prefix.Contains(addr):
+		return []netip.Addr{netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]})}
+	case sixToFour.Contains(addr):
+		return []netip.Addr{netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]})}
+	case teredoPrefix.Contains(addr):
+		return []netip.Addr{
+			netip.AddrFrom4([4]byte{b[4], b[5], b[6], b[7]}),
+			netip.AddrFrom4([4]byte{^b[12], ^b[13], ^b[14], ^b[15]}),
+		}
+	default:
+		return nil
+	}
+}
+
+// fileName prefers the Content-Disposition filename and falls back to the last URL path segment.
+func fileName(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return path.Base(params["filename"])
+	}
+	if name := path.Base(resp.Request.URL.Path); name != "." && name != "/" {
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			return unescaped
+		}
+		return name
+	}
+	return resp.Request.URL.Hostname()
+}
@@ -0,0 +1,39 @@
+package fetcher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCheckAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		blocked bool
+	}{
+		{"93.184.216.34:443", false},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", false},
+		{"127.0.0.1:80", true},
+		{"10.0.0.1:80", true},
+		{"169.254.169.254:80", true},
+		{"[::1]:80", true},
+		{"[::ffff:10.0.0.1]:80", true},
+		// NAT64 of 10.0.0.1 and of a public address.
+		{"[64:ff9b::a00:1]:80", true},
+		{"[64:ff9b::5db8:d822]:80", false},
+		{"[64:ff9b:1::a00:1]:80", true},
+		// 6to4 of 192.168.1.1 and of a public address.
+		{"[2002:c0a8:101::1]:80", true},
+		{"[2002:5db8:d822::1]:80", false},
+		// Teredo with server 65.54.227.120 and client 127.0.0.1 (inverted), then a private
+		// server with a public client.
+		{"[2001:0:4136:e378:8000:63bf:80ff:fffe]:80", true},
+		{"[2001:0:a00:1:8000:63bf:a247:27dd]:80", true},
+		{"[2001:0:4136:e378:8000:63bf:a247:27dd]:80", false},
+	}
+	for _, tt := range tests {
+		err := checkAddress(tt.address)
+		if blocked := errors.Is(err, ErrBlockedAddress); blocked != tt.blocked {
+			t.Errorf("checkAddress(%s) = %v, want blocked %v", tt.address, err, tt.blocked)
+		}
+	}
+}
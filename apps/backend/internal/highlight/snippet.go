@@ -0,0 +1,140 @@
+// Package highlight picks the passage of a text that best matches a search query.
+package highlight
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const ellipsis = "…"
+
+var wordRegex = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// stopWords are query words too common to be worth highlighting.
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "are": true, "was": true, "were": true,
+	"with": true, "what": true, "which": true, "who": true, "how": true, "why": true,
+	"when": true, "where": true, "this": true, "that": true, "these": true, "those": true,
+	"from": true, "into": true, "about": true, "does": true, "did": true, "has": true,
+	"have": true, "had": true, "not": true, "any": true, "all": true, "our": true, "their": true,
+}
+
+// minPrefixTermLength is the shortest query term that also matches longer words starting
+// with it, so "revenue" finds "revenues".
+const minPrefixTermLength = 4
+
+type match struct{ start, end int }
+
+// Snippet returns the window of about maxBytes bytes of text holding the most occurrences
+// of the query's terms, with whitespace collapsed. The snippet is HTML-escaped and the
+// occurrences are wrapped in <mark> tags. Without any occurrence the start of the text is
+// returned.
+func Snippet(text, query string, maxBytes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	matches := findTerms(text, queryTerms(query))
+
+	// Find the window starting at a match that covers the most matches.
+	best, bestCount := 0, 0
+	for i := range matches {
+		count := 0
+		for j := i; j < len(matches) && matches[j].end-matches[i].start <= maxBytes; j++ {
+			count++
+		}
+		if count > bestCount {
+			best, bestCount = i, count
+		}
+	}
+
+	start := 0
+	if bestCount > 0 {
+		first, last := matches[best], matches[best+bestCount-1]
+		// Centre the matches in the window.
+		start = max(0, first.start-(maxBytes-(last.end-first.start))/2)
+	}
+	end := min(len(text), start+maxBytes)
+	start = max(0, min(start, end-maxBytes))
+
+	// Cut at spaces so no word, or UTF-8 sequence, is split.
+	if start > 0 {
+		if i := strings.IndexByte(text[start:end], ' '); i >= 0 {
+			start += i + 1
+		} else {
+			for start < end && !utf8.RuneStart(text[start]) {
+				start++
+			}
+		}
+	}
+	if end < len(text) {
+		if i := strings.LastIndexByte(text[start:end], ' '); i > 0 {
+			end = start + i
+		} else {
+			for end > start && !utf8.RuneStart(text[end]) {
+				end--
+			}
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString(ellipsis)
+	}
+	pos := start
+	for _, m := range matches {
+		if m.start < start || m.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:m.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[m.start:m.end]))
+		b.WriteString("</mark>")
+		pos = m.end
+	}
+	b.WriteString(html.EscapeString(text[pos:end]))
+	if end < len(text) {
+		b.WriteString(ellipsis)
+	}
+	return b.String()
+}
+
+// queryTerms returns the distinct lower-cased words of the query worth highlighting.
+func queryTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, word := range wordRegex.FindAllString(strings.ToLower(query), -1) {
+		if seen[word] || stopWords[word] || (utf8.RuneCountInString(word) < 3 && !isNumber(word)) {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// findTerms returns the words of text matching one of the terms, in order.
+func findTerms(text string, terms []string) []match {
+	if len(terms) == 0 {
+		return nil
+	}
+	var matches []match
+	for _, loc := range wordRegex.FindAllStringIndex(text, -1) {
+		word := strings.ToLower(text[loc[0]:loc[1]])
+		for _, term := range terms {
+			if word == term || (len(term) >= minPrefixTermLength && strings.HasPrefix(word, term)) {
+				matches = append(matches, match{loc[0], loc[1]})
+				break
+			}
+		}
+	}
+	return matches
+}
+
+func isNumber(word string) bool {
+	for _, r := range word {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net/http"
 	"strategic-insight-analyst/backend/config"
 	"strings"
 
@@ -12,43 +13,102 @@ import (
 
 var model = flag.String("model", "gemini-2.0-flash", "the model name, e.g. gemini-2.0-flash")
 
-// GetEmbedding generates an embedding for the given text using the Gemini API.
-func GetEmbedding(text string) ([]float32, error) {
-	ctx := context.Background()
+// EmbeddingModel identifies an embedding model and the dimensionality requested from it.
+// Embeddings are only comparable when both match.
+type EmbeddingModel struct {
+	Name       string
+	Dimensions int
+}
+
+func (m EmbeddingModel) String() string {
+	return fmt.Sprintf("%s/%d", m.Name, m.Dimensions)
+}
+
+// maxEmbeddingBatchSize is the most texts the Gemini API accepts in one batch embedding request.
+const maxEmbeddingBatchSize = 100
+
+// client is shared by all calls; genai clients are safe for concurrent use.
+var client *genai.Client
+
+// Initialize creates the shared Gemini client. Its requests go through a transport that
+// retries transient failures and rate limits calls per model.
+func Initialize() error {
 	apiKey := config.AppConfig.GeminiAPIKey
 	if apiKey == "" {
-		return nil, fmt.Errorf("GEMINI_API_KEY not set in config")
+		return fmt.Errorf("GEMINI_API_KEY not set in config")
 	}
 
-	client, err := genai.NewClient(ctx, nil)
+	cfg := config.AppConfig
+	transport := newResilientTransport(http.DefaultTransport, TransportConfig{
+		MaxRetries:        int(cfg.LLMMaxRetries),
+		BaseDelay:         cfg.LLMRetryBaseDelay,
+		MaxDelay:          cfg.LLMRetryMaxDelay,
+		RequestsPerMinute: int(cfg.LLMRequestsPerMinute),
+		Burst:             int(cfg.LLMRateLimitBurst),
+		BreakerThreshold:  int(cfg.LLMBreakerThreshold),
+		BreakerCooldown:   cfg.LLMBreakerCooldown,
+	})
+
+	var err error
+	client, err = genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: &http.Client{Transport: transport},
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create client: %w", err)
-	}
-
-	contents := []*genai.Content{
-		genai.NewContentFromText(text, genai.RoleUser),
+		return fmt.Errorf("failed to create Gemini client: %w", err)
 	}
+	return nil
+}
 
-	result, err := client.Models.EmbedContent(ctx, "text-embedding-004", contents, &genai.EmbedContentConfig{OutputDimensionality: genai.Ptr[int32](768)})
-
+// GetEmbedding generates an embedding for the given text using the Gemini API.
+func GetEmbedding(ctx context.Context, model EmbeddingModel, text string) ([]float32, error) {
+	embeddings, err := GetEmbeddings(ctx, model, []string{text})
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate embedding: %w", err)
+		return nil, err
 	}
+	return embeddings[0], nil
+}
 
-	if result != nil && len(result.Embeddings) > 0 && result.Embeddings[0] != nil {
-		return result.Embeddings[0].Values, nil
+// GetEmbeddings generates embeddings for several texts using the batch embedding API.
+// The result has one embedding per text, in the same order. Cancelling ctx stops the
+// calls, including retries and waits for the rate limiter.
+func GetEmbeddings(ctx context.Context, model EmbeddingModel, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+
+	for start := 0; start < len(texts); start += maxEmbeddingBatchSize {
+		end := min(start+maxEmbeddingBatchSize, len(texts))
+
+		contents := make([]*genai.Content, 0, end-start)
+		for _, text := range texts[start:end] {
+			contents = append(contents, genai.NewContentFromText(text, genai.RoleUser))
+		}
+
+		result, err := client.Models.EmbedContent(ctx, model.Name, contents, &genai.EmbedContentConfig{OutputDimensionality: genai.Ptr(int32(model.Dimensions))})
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate embeddings: %w", err)
+		}
+		if result == nil || len(result.Embeddings) != len(contents) {
+			return nil, fmt.Errorf("expected %d embeddings in response", len(contents))
+		}
+
+		for _, embedding := range result.Embeddings {
+			if embedding == nil {
+				return nil, fmt.Errorf("no embedding found in response")
+			}
+			if len(embedding.Values) != model.Dimensions {
+				return nil, fmt.Errorf("%s returned %d dimensions, expected %d", model.Name, len(embedding.Values), model.Dimensions)
+			}
+			embeddings = append(embeddings, embedding.Values)
+		}
 	}
 
-	return nil, fmt.Errorf("no embedding found in response")
+	return embeddings, nil
 }
 
 func CallGeminiStream(query string, contextText string, history []*genai.Content, hasAttachedDocs bool, streamChan chan<- string) (string, error) {
 	defer close(streamChan)
 	ctx := context.Background()
-	apiKey := config.AppConfig.GeminiAPIKey
-	if apiKey == "" {
-		return "", fmt.Errorf("GEMINI_API_KEY not set in config")
-	}
 
 	systemInstruction := `You are a sophisticated AI assistant specializing in strategic analysis. Your primary function is to deliver precise, insightful, and concise answers based *exclusively* on the provided document context.
 
@@ -62,11 +122,6 @@ Key Instructions:
 		SystemInstruction: genai.NewContentFromText(systemInstruction, genai.RoleUser),
 	}
 
-	client, err := genai.NewClient(ctx, nil)
-	if err != nil {
-		return "", err
-	}
-
 	chat, err := client.Chats.Create(ctx, *model, config, history)
 	if err != nil {
 		return "", err
@@ -95,7 +150,13 @@ Provide a clear, concise, and analytical response. If the query suggests a forma
 
 	stream := chat.SendMessageStream(ctx, genai.Part{Text: prompt})
 	var fullResponse strings.Builder
-	for chunk, _ := range stream {
+	for chunk, err := range stream {
+		if err != nil {
+			return fullResponse.String(), fmt.Errorf("failed to stream response: %w", err)
+		}
+		if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil || len(chunk.Candidates[0].Content.Parts) == 0 {
+			continue
+		}
 
 		part := chunk.Candidates[0].Content.Parts[0]
 		streamChan <- part.Text
@@ -105,3 +166,29 @@ Provide a clear, concise, and analytical response. If the query suggests a forma
 
 	return fullResponse.String(), nil
 }
+
+// SummarizeChanges asks the model for a short summary of what changed between two versions
+// of a document, given a textual description of the changed sections.
+func SummarizeChanges(fileName string, fromVersion, toVersion int, changes string) (string, error) {
+	ctx := context.Background()
+
+	systemInstruction := `You are an analyst comparing two versions of a strategy document. Summarize what changed between them using *only* the changed sections you are given. Lead with the most significant changes (e.g. shifts in goals, numbers, priorities, risks or timelines), group related edits, and skip purely cosmetic changes. Be concise and do not speculate about sections you were not shown.`
+
+	config := &genai.GenerateContentConfig{
+		Temperature:       genai.Ptr[float32](0.3),
+		SystemInstruction: genai.NewContentFromText(systemInstruction, genai.RoleUser),
+	}
+
+	prompt := fmt.Sprintf(`Document: %s
+Changes from version %d to version %d:
+
+%s
+
+Summarize what changed, as a short paragraph followed by a bulleted list of the key changes.`, fileName, fromVersion, toVersion, changes)
+
+	result, err := client.Models.GenerateContent(ctx, *model, genai.Text(prompt), config)
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize changes: %w", err)
+	}
+	return result.Text(), nil
+}
@@ -0,0 +1,293 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrCircuitOpen is returned without contacting the API while a model's circuit breaker
+// is open after repeated failures.
+var ErrCircuitOpen = errors.New("Gemini API temporarily unavailable after repeated failures")
+
+// maxErrorBodySize bounds how much of an error response is buffered to look for a retry delay.
+const maxErrorBodySize = 64 << 10
+
+// TransportConfig controls retries, rate limiting and circuit breaking of Gemini API calls.
+type TransportConfig struct {
+	// MaxRetries is how many times a failed request is retried; 0 disables retries.
+	MaxRetries int
+	// BaseDelay and MaxDelay bound the exponential backoff. A server-advised delay
+	// longer than MaxDelay is not waited for; the error is returned instead.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// RequestsPerMinute and Burst configure the token bucket shared by all calls to a
+	// model. RequestsPerMinute 0 disables rate limiting.
+	RequestsPerMinute int
+	Burst             int
+	// BreakerThreshold consecutive failures open a model's circuit for BreakerCooldown.
+	// BreakerThreshold 0 disables circuit breaking.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+}
+
+// resilientTransport retries transient failures (429, 5xx, network errors) with
+// exponential backoff and full jitter, honours Retry-After and the API's RetryInfo, and
+// applies a rate limiter and circuit breaker per model. It works below the genai client,
+// so it covers unary and streaming calls alike; streamed responses are only retried
+// before any of the body has been read.
+type resilientTransport struct {
+	base http.RoundTripper
+	cfg  TransportConfig
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	breakers map[string]*breaker
+}
+
+func newResilientTransport(base http.RoundTripper, cfg TransportConfig) *resilientTransport {
+	return &resilientTransport{
+		base:     base,
+		cfg:      cfg,
+		limiters: make(map[string]*rate.Limiter),
+		breakers: make(map[string]*breaker),
+	}
+}
+
+func (t *resilientTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	model := modelFromPath(req.URL.Path)
+	limiter, breaker := t.forModel(model)
+	ctx := req.Context()
+
+	for attempt := 0; ; attempt++ {
+		if !breaker.allow() {
+			return nil, fmt.Errorf("%w (model %s)", ErrCircuitOpen, model)
+		}
+		if limiter != nil {
+			if err := limiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+
+		attemptReq := req
+		if attempt > 0 {
+			if req.GetBody == nil && req.Body != nil {
+				return nil, fmt.Errorf("cannot retry request to %s: body is not replayable", model)
+			}
+			attemptReq = req.Clone(ctx)
+			if req.GetBody != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if ctx.Err() != nil {
+			// The caller gave up; that says nothing about the API's health.
+			return resp, err
+		}
+		if !isRetryable(resp, err) {
+			breaker.record(true)
+			return resp, err
+		}
+		breaker.record(false)
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if advised, ok := retryDelay(resp); ok {
+				if advised > t.cfg.MaxDelay {
+					return resp, nil
+				}
+				delay = advised
+			}
+		}
+		if attempt >= t.cfg.MaxRetries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		status := "error: " + fmt.Sprint(err)
+		if resp != nil {
+			status = resp.Status
+		}
+		log.Printf("Gemini request to %s failed (%s), retrying in %s (attempt %d of %d)", model, status, delay.Round(time.Millisecond), attempt+1, t.cfg.MaxRetries)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// forModel returns the model's rate limiter (nil when unlimited) and circuit breaker,
+// creating them on first use.
+func (t *resilientTransport) forModel(model string) (*rate.Limiter, *breaker) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, ok := t.breakers[model]
+	if !ok {
+		b = &breaker{threshold: t.cfg.BreakerThreshold, cooldown: t.cfg.BreakerCooldown}
+		t.breakers[model] = b
+	}
+	if t.cfg.RequestsPerMinute <= 0 {
+		return nil, b
+	}
+	l, ok := t.limiters[model]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(float64(t.cfg.RequestsPerMinute)/60), max(t.cfg.Burst, 1))
+		t.limiters[model] = l
+	}
+	return l, b
+}
+
+// backoff returns a random delay in [0, min(MaxDelay, BaseDelay*2^attempt)] ("full jitter").
+func (t *resilientTransport) backoff(attempt int) time.Duration {
+	ceiling := t.cfg.MaxDelay
+	if attempt < 32 {
+		ceiling = min(t.cfg.BaseDelay<<attempt, t.cfg.MaxDelay)
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling + 1)
+}
+
+func isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// retryDelay reads the server-advised delay from the Retry-After header (seconds or an
+// HTTP date) or, failing that, from a google.rpc.RetryInfo detail in the error body.
+// The body is peeked at and restored so the caller can still read it.
+func retryDelay(resp *http.Response) (time.Duration, bool) {
+	if value := resp.Header.Get("Retry-After"); value != "" {
+		if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+			return time.Duration(seconds) * time.Second, true
+		}
+		if date, err := http.ParseTime(value); err == nil {
+			return max(time.Until(date), 0), true
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	if err != nil {
+		return 0, false
+	}
+
+	var payload struct {
+		Error struct {
+			Details []struct {
+				Type       string `json:"@type"`
+				RetryDelay string `json:"retryDelay"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &payload) != nil {
+		return 0, false
+	}
+	for _, detail := range payload.Error.Details {
+		if strings.HasSuffix(detail.Type, "google.rpc.RetryInfo") {
+			if delay, err := time.ParseDuration(detail.RetryDelay); err == nil && delay >= 0 {
+				return delay, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// modelFromPath extracts the model name from API paths such as
+// "/v1beta/models/gemini-2.0-flash:streamGenerateContent".
+func modelFromPath(path string) string {
+	_, after, ok := strings.Cut(path, "/models/")
+	if !ok {
+		return "default"
+	}
+	model, _, _ := strings.Cut(after, ":")
+	return model
+}
+
+// breaker is a consecutive-failure circuit breaker. Once open it rejects calls until the
+// cooldown has passed, then lets a single trial call through per cooldown: success closes
+// the circuit, failure keeps it open.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func (b *breaker) allow() bool {
+	if b.threshold <= 0 {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < b.threshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) {
+		return false
+	}
+	// Hold everyone else back while the trial call is in flight.
+	b.openUntil = time.Now().Add(b.cooldown)
+	return true
+}
+
+func (b *breaker) record(success bool) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	wasOpen := b.failures >= b.threshold
+	if success {
+		if wasOpen {
+			log.Printf("Gemini circuit breaker closed")
+		}
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		if !wasOpen {
+			log.Printf("Gemini circuit breaker open for %s after %d consecutive failures", b.cooldown, b.failures)
+		}
+		b.openUntil = time.Now().Add(b.cooldown)
+	}
+}
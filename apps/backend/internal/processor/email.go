@@ -0,0 +1,283 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EmailMessage is a parsed email with its decoded body and attachments.
+type EmailMessage struct {
+	// Metadata holds the decoded From/To/Cc/Date/Subject/Message-ID headers.
+	Metadata    map[string]string
+	Body        string
+	Attachments []Attachment
+}
+
+// Attachment is a file attached to an email, already decoded from its transfer encoding.
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Data        []byte
+}
+
+// emailMetadataHeaders maps the headers kept as chunk metadata to their metadata keys.
+var emailMetadataHeaders = []struct {
+	Header string
+	Key    string
+}{
+	{"From", "from"},
+	{"To", "to"},
+	{"Cc", "cc"},
+	{"Date", "date"},
+	{"Subject", "subject"},
+	{"Message-Id", "message_id"},
+}
+
+var (
+	htmlBlockRegex   = regexp.MustCompile(`(?is)<(script|style)[^>]*>.*?</(script|style)>`)
+	htmlTagRegex     = regexp.MustCompile(`(?s)<[^>]*>`)
+	blankLinesRegex  = regexp.MustCompile(`\n\s*\n+`)
+	headerDecoder    = &mime.WordDecoder{CharsetReader: charsetReader}
+	mboxEscapedRegex = regexp.MustCompile(`^>+From `)
+)
+
+// headerGetter is implemented by both mail.Header and textproto.MIMEHeader.
+type headerGetter interface {
+	Get(key string) string
+}
+
+// ExtractEmails parses an .eml ("message/rfc822") or .mbox ("application/mbox") file
+// into its messages.
+func ExtractEmails(file io.Reader, fileType string) ([]*EmailMessage, error) {
+	switch fileType {
+	case "message/rfc822":
+		msg, err := ParseEML(file)
+		if err != nil {
+			return nil, err
+		}
+		return []*EmailMessage{msg}, nil
+	case "application/mbox":
+		return ParseMbox(file)
+	default:
+		return nil, fmt.Errorf("unsupported file type for email extraction: %s", fileType)
+	}
+}
+
+// ParseEML parses a single RFC 822 message, walking its MIME structure to collect the
+// text body and any attachments.
+func ParseEML(r io.Reader) (*EmailMessage, error) {
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse email: %w", err)
+	}
+
+	msg := &EmailMessage{Metadata: make(map[string]string)}
+	for _, h := range emailMetadataHeaders {
+		value := m.Header.Get(h.Header)
+		if value == "" {
+			continue
+		}
+		if decoded, err := headerDecoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		if h.Header == "Date" {
+			if date, err := mail.ParseDate(value); err == nil {
+				value = date.Format(time.RFC3339)
+			}
+		}
+		msg.Metadata[h.Key] = value
+	}
+
+	var plainParts, htmlParts []string
+	if err := msg.walk(m.Header, m.Body, &plainParts, &htmlParts); err != nil {
+		return nil, err
+	}
+
+	// Prefer the plain text alternative; fall back to stripped HTML.
+	if len(plainParts) > 0 {
+		msg.Body = strings.Join(plainParts, "\n\n")
+	} else {
+		msg.Body = strings.Join(htmlParts, "\n\n")
+	}
+	return msg, nil
+}
+
+// ParseMbox splits an mbox file into messages on "From " separator lines and parses each.
+// Messages that cannot be parsed are logged and skipped.
+func ParseMbox(r io.Reader) ([]*EmailMessage, error) {
+	var messages []*EmailMessage
+	var current bytes.Buffer
+	inMessage := false
+
+	flush := func() {
+		if !inMessage || current.Len() == 0 {
+			return
+		}
+		msg, err := ParseEML(bytes.NewReader(current.Bytes()))
+		if err != nil {
+			log.Printf("Warning: skipping malformed message %d in mbox: %v", len(messages)+1, err)
+		} else {
+			messages = append(messages, msg)
+		}
+		current.Reset()
+	}
+
+	br := bufio.NewReader(r)
+	prevBlank := true
+	for {
+		line, err := br.ReadString('\n')
+		if len(line) > 0 {
+			if prevBlank && strings.HasPrefix(line, "From ") {
+				flush()
+				inMessage = true
+			} else if inMessage {
+				// Undo the mboxrd quoting of body lines that start with "From ".
+				if mboxEscapedRegex.MatchString(line) {
+					line = line[1:]
+				}
+				current.WriteString(line)
+			}
+			prevBlank = strings.TrimRight(line, "\r\n") == ""
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mbox: %w", err)
+		}
+	}
+	flush()
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("no messages found in mbox file")
+	}
+	return messages, nil
+}
+
+// Text returns the message as plain text for chunking, with the main headers
+// prepended so they are visible to retrieval and the LLM.
+func (m *EmailMessage) Text() string {
+	var b strings.Builder
+	for _, h := range emailMetadataHeaders {
+		if value, ok := m.Metadata[h.Key]; ok && h.Key != "message_id" {
+			b.WriteString(fmt.Sprintf("%s: %s\n", h.Header, value))
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(m.Body)
+	return b.String()
+}
+
+func (m *EmailMessage) walk(h headerGetter, body io.Reader, plainParts, htmlParts *[]string) error {
+	mediaType, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		// RFC 2045 default for a missing or broken Content-Type.
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		mr := multipart.NewReader(body, params["boundary"])
+		for {
+			// NextRawPart keeps the Content-Transfer-Encoding so all parts are decoded the same way.
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to read multipart section: %w", err)
+			}
+			if err := m.walk(part.Header, part, plainParts, htmlParts); err != nil {
+				return err
+			}
+		}
+	}
+
+	data, err := io.ReadAll(decodeTransferEncoding(h.Get("Content-Transfer-Encoding"), body))
+	if err != nil {
+		return fmt.Errorf("failed to decode %s part: %w", mediaType, err)
+	}
+
+	disposition, dispositionParams, _ := mime.ParseMediaType(h.Get("Content-Disposition"))
+	fileName := dispositionParams["filename"]
+	if fileName == "" {
+		fileName = params["name"]
+	}
+	if decoded, err := headerDecoder.DecodeHeader(fileName); err == nil {
+		fileName = decoded
+	}
+
+	isText := mediaType == "text/plain" || mediaType == "text/html"
+	if disposition == "attachment" || !isText {
+		if fileName == "" {
+			if mediaType != "message/rfc822" {
+				// Inline parts without a name (e.g. signature images) are not worth ingesting.
+				return nil
+			}
+			fileName = "attached-message.eml"
+		}
+		m.Attachments = append(m.Attachments, Attachment{FileName: fileName, ContentType: mediaType, Data: data})
+		return nil
+	}
+
+	text := decodeCharset(data, params["charset"])
+	if mediaType == "text/html" {
+		*htmlParts = append(*htmlParts, stripHTML(text))
+	} else {
+		*plainParts = append(*plainParts, text)
+	}
+	return nil
+}
+
+func decodeTransferEncoding(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		// Line breaks inside base64 bodies are ignored by the decoder.
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// decodeCharset converts Latin-1 style charsets to UTF-8. Other charsets are assumed
+// to be UTF-8 compatible.
+func decodeCharset(data []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252", "cp1252":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		return string(data)
+	}
+}
+
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.NewReader(decodeCharset(data, charset)), nil
+}
+
+func stripHTML(s string) string {
+	s = htmlBlockRegex.ReplaceAllString(s, "")
+	s = htmlTagRegex.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(s)
+	return strings.TrimSpace(blankLinesRegex.ReplaceAllString(s, "\n\n"))
+}
@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+var (
+	// ErrUnsupportedFileType is returned when a file's type can't be processed.
+	ErrUnsupportedFileType = errors.New("unsupported file type")
+	// ErrFileTypeMismatch is returned when a file's content doesn't match its extension or declared type.
+	ErrFileTypeMismatch = errors.New("file content does not match its type")
+)
+
+// SniffLen is the number of leading bytes DetectFileType looks at.
+const SniffLen = 512
+
+// SupportedFileTypes lists the content types that can be uploaded and processed.
+var SupportedFileTypes = []string{"application/pdf", "text/plain", "text/html", "image/png", "image/jpeg", "image/tiff", "message/rfc822", "application/mbox"}
+
+// extensionFileTypes maps file extensions to the type the file claims to be.
+var extensionFileTypes = map[string]string{
+	".pdf":  "application/pdf",
+	".txt":  "text/plain",
+	".html": "text/html",
+	".htm":  "text/html",
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".eml":  "message/rfc822",
+	".mbox": "application/mbox",
+	".zip":  "application/zip",
+}
+
+// fileTypeExtensions is the preferred extension for each supported content type.
+var fileTypeExtensions = map[string]string{
+	"application/pdf":  ".pdf",
+	"text/plain":       ".txt",
+	"text/html":        ".html",
+	"image/png":        ".png",
+	"image/jpeg":       ".jpg",
+	"image/tiff":       ".tiff",
+	"message/rfc822":   ".eml",
+	"application/mbox": ".mbox",
+	"application/zip":  ".zip",
+}
+
+// magicSignatures identifies binary formats by their leading bytes.
+var magicSignatures = []struct {
+	fileType string
+	magic    []byte
+}{
+	{"application/pdf", []byte("%PDF-")},
+	{"image/png", []byte("\x89PNG\r\n\x1a\n")},
+	{"image/jpeg", []byte("\xff\xd8\xff")},
+	{"image/tiff", []byte("II*\x00")},
+	{"image/tiff", []byte("MM\x00*")},
+	{"application/zip", []byte("PK\x03\x04")},
+	{"application/zip", []byte("PK\x05\x06")}, // empty archive
+}
+
+// emailHeaderRegex matches headers that only appear at the top of an RFC 822 message.
+var emailHeaderRegex = regexp.MustCompile(`(?im)^(received|return-path|message-id|mime-version|delivered-to|from|subject):\s`)
+
+// DetectFileType determines a file's real type from its leading bytes (see SniffLen) and
+// checks it against what the file claims to be, based on its extension or, failing that,
+// the declared Content-Type. A file whose content contradicts its claimed type returns
+// ErrFileTypeMismatch. Text formats can't be told apart reliably from content alone, so
+// among those the claimed type wins.
+func DetectFileType(head []byte, declaredType string, fileName string) (string, error) {
+	claimed := claimedFileType(declaredType, fileName)
+	detected := detectFromContent(head)
+
+	switch {
+	case detected == "":
+		return "", fmt.Errorf("%w: the file content is not a recognised format", ErrUnsupportedFileType)
+	case detected == claimed:
+		return detected, nil
+	case claimed == "":
+		// Office documents and other ZIP-based formats must not be fanned out as archives.
+		if IsArchiveFileType(detected) {
+			return "", fmt.Errorf("%w: ZIP-based files are only accepted as .zip archives", ErrUnsupportedFileType)
+		}
+		return detected, nil
+	case isTextFileType(claimed) && isTextFileType(detected):
+		return claimed, nil
+	default:
+		return "", fmt.Errorf("%w: the content is %s but the file was uploaded as %s", ErrFileTypeMismatch, detected, claimed)
+	}
+}
+
+// claimedFileType returns the type implied by the file extension, falling back to the
+// declared Content-Type when the extension is missing or unknown. Unsupported or generic
+// declared types (e.g. application/octet-stream) are ignored.
+func claimedFileType(declaredType string, fileName string) string {
+	if fileType, ok := extensionFileTypes[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return fileType
+	}
+	mediaType, _, err := mime.ParseMediaType(declaredType)
+	if err != nil {
+		return ""
+	}
+	if mediaType == "application/x-zip-compressed" {
+		mediaType = "application/zip"
+	}
+	if IsSupportedFileType(mediaType) || IsArchiveFileType(mediaType) {
+		return mediaType
+	}
+	return ""
+}
+
+func detectFromContent(head []byte) string {
+	for _, sig := range magicSignatures {
+		if bytes.HasPrefix(head, sig.magic) {
+			return sig.fileType
+		}
+	}
+
+	sniffed := http.DetectContentType(head)
+	if strings.HasPrefix(sniffed, "text/html") {
+		return "text/html"
+	}
+	if !strings.HasPrefix(sniffed, "text/") {
+		return ""
+	}
+
+	text := bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	switch {
+	case bytes.HasPrefix(text, []byte("From ")):
+		return "application/mbox"
+	case startsWithHeader(text) && emailHeaderRegex.Match(text):
+		return "message/rfc822"
+	default:
+		return "text/plain"
+	}
+}
+
+// startsWithHeader reports whether the first line looks like an RFC 822 header field.
+func startsWithHeader(text []byte) bool {
+	line, _, _ := bytes.Cut(text, []byte("\n"))
+	name, _, found := bytes.Cut(line, []byte(":"))
+	return found && len(name) > 0 && !bytes.ContainsAny(name, " \t")
+}
+
+func isTextFileType(fileType string) bool {
+	switch fileType {
+	case "text/plain", "text/html", "message/rfc822", "application/mbox":
+		return true
+	}
+	return false
+}
+
+// IsSupportedFileType reports whether the given content type can be processed.
+func IsSupportedFileType(fileType string) bool {
+	for _, t := range SupportedFileTypes {
+		if t == fileType {
+			return true
+		}
+	}
+	return false
+}
+
+// IsArchiveFileType reports whether the content type is an archive that fans out into
+// several documents rather than being processed itself.
+func IsArchiveFileType(fileType string) bool {
+	return fileType == "application/zip"
+}
+
+// EnsureExtension appends the preferred extension for fileType when fileName has none,
+// so files named after URLs like /reports/latest still download with a usable name.
+func EnsureExtension(fileName string, fileType string) string {
+	if filepath.Ext(fileName) != "" {
+		return fileName
+	}
+	return fileName + fileTypeExtensions[fileType]
+}
@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrNoText is returned when none of the extraction methods (text layer or OCR)
+// produce any text for a document.
+var ErrNoText = errors.New("no text could be extracted from the document (it may be empty, encrypted or an unreadable scan)")
+
+// imageExtensions maps the supported image content types to the file extension
+// tesseract uses to detect the input format.
+var imageExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/tiff": ".tiff",
+}
+
+// ocrImageFile runs tesseract on the image at the given path and returns the recognised text.
+// NOTE: This function requires the `tesseract-ocr` package (which provides `tesseract`)
+// to be installed on the system running the backend.
+func ocrImageFile(path string) (string, error) {
+	var stderr bytes.Buffer
+	// Writing to "stdout" makes tesseract print the text instead of creating an output file.
+	cmd := exec.Command("tesseract", path, "stdout")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run tesseract command: %w (%s). Ensure tesseract-ocr is installed", err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
+
+// extractTextFromImage writes the uploaded image to a temporary file and runs OCR on it.
+// Multi-page TIFFs are handled natively by tesseract.
+func extractTextFromImage(file io.Reader, fileType string) (string, error) {
+	inputFile, err := os.CreateTemp("", "upload-*"+imageExtensions[fileType])
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp input file: %w", err)
+	}
+	defer os.Remove(inputFile.Name())
+
+	if _, err := io.Copy(inputFile, file); err != nil {
+		inputFile.Close()
+		return "", fmt.Errorf("failed to copy to temp file: %w", err)
+	}
+	inputFile.Close()
+
+	return ocrImageFile(inputFile.Name())
+}
+
+// ocrPDFPage rasterizes a single page of a PDF and runs OCR on the resulting image.
+// Pages are 1-indexed, matching pdftoppm.
+// NOTE: `pdftoppm` is provided by the same `poppler-utils` package as `pdftotext`.
+func ocrPDFPage(pdfPath string, page int) (string, error) {
+	dir, err := os.MkdirTemp("", "ocr-*")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	prefix := filepath.Join(dir, "page")
+	pageNum := strconv.Itoa(page)
+	// 300 DPI is the resolution tesseract is tuned for.
+	cmd := exec.Command("pdftoppm", "-r", "300", "-f", pageNum, "-l", pageNum, "-png", "-singlefile", pdfPath, prefix)
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to run pdftoppm command for page %d: %w. Ensure poppler-utils is installed", page, err)
+	}
+
+	return ocrImageFile(prefix + ".png")
+}
@@ -5,43 +5,123 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"os"
 	"os/exec"
+	"slices"
 	"strings"
+	"unicode/utf8"
 )
 
-// ExtractText handles non-streaming text extraction for simple file types.
+// ExtractText extracts the whole text of a file. Images are run through OCR, as are PDF
+// pages without a text layer; emails are returned message by message with their headers.
+// Ingestion chunks PDFs with ProcessPDFChunks instead.
 func ExtractText(file io.Reader, fileType string) (string, error) {
 	switch fileType {
+	case "application/pdf":
+		return extractTextFromPDF(file)
+	case "message/rfc822", "application/mbox":
+		messages, err := ExtractEmails(file, fileType)
+		if err != nil {
+			return "", err
+		}
+		texts := make([]string, len(messages))
+		for i, msg := range messages {
+			texts[i] = msg.Text()
+		}
+		return strings.Join(texts, "\n\n"), nil
 	case "text/plain":
 		return extractTextFromTXT(file)
+	case "text/html":
+		return extractTextFromHTML(file)
+	case "image/png", "image/jpeg", "image/tiff":
+		return extractTextFromImage(file, fileType)
 	default:
 		return "", fmt.Errorf("unsupported file type for non-streaming extraction: %s", fileType)
 	}
 }
 
-// ProcessPDFChunks extracts text from a PDF, chunks it, and processes each chunk via a callback.
-// It uses the `pdftotext` command-line tool.
+// PageRange is the span of PDF pages, numbered from 1, that a chunk was taken from.
+type PageRange struct {
+	First int
+	Last  int
+}
+
+// ProcessPDFChunks extracts text from a PDF, chunks it, and processes each chunk via a callback
+// along with the pages it spans.
+// It uses the `pdftotext` command-line tool. Pages without a text layer (e.g. scans) are
+// rasterized and run through OCR. ErrNoText is returned if no page yields any text.
 // NOTE: This function requires the `poppler-utils` package (which provides `pdftotext`)
 // to be installed on the system running the backend.
-func ProcessPDFChunks(file io.Reader, chunkSize int, overlap int, processChunk func(chunk string, chunkIndex int) error) error {
+func ProcessPDFChunks(file io.Reader, chunkSize int, overlap int, processChunk func(chunk string, chunkIndex int, pages PageRange) error) error {
+	pages, err := extractPDFPages(file)
+	if err != nil {
+		return err
+	}
+	text := strings.Join(pages, "\n")
+
+	// pageStarts[i] is the rune offset in text at which page i+1 starts.
+	pageStarts := make([]int, len(pages))
+	offset := 0
+	for i, page := range pages {
+		pageStarts[i] = offset
+		offset += utf8.RuneCountInString(page) + 1
+	}
+	pageAt := func(runeOffset int) int {
+		page, found := slices.BinarySearch(pageStarts, runeOffset)
+		if !found {
+			page--
+		}
+		return page + 1
+	}
+
+	// Use the existing rune-safe ChunkText function
+	chunks := ChunkText(text, chunkSize, overlap)
+
+	// Process each chunk
+	start := 0
+	for i, chunk := range chunks {
+		end := start + utf8.RuneCountInString(chunk) - 1
+		if err := processChunk(chunk, i, PageRange{First: pageAt(start), Last: pageAt(end)}); err != nil {
+			// If the callback returns an error, abort the processing and return the error.
+			return fmt.Errorf("failed to process chunk %d: %w", i, err)
+		}
+		// ChunkText starts each chunk chunkSize-overlap runes after the previous one.
+		start += chunkSize - overlap
+	}
+
+	return nil
+}
+
+// extractTextFromPDF runs pdftotext on the PDF and OCRs the pages without a text layer.
+func extractTextFromPDF(file io.Reader) (string, error) {
+	pages, err := extractPDFPages(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(pages, "\n"), nil
+}
+
+// extractPDFPages returns the text of each page of the PDF, running pdftotext and OCRing
+// the pages without a text layer.
+func extractPDFPages(file io.Reader) ([]string, error) {
 	// Create a temporary file for the uploaded PDF
 	inputFile, err := ioutil.TempFile("", "upload-*.pdf")
 	if err != nil {
-		return fmt.Errorf("failed to create temp input file: %w", err)
+		return nil, fmt.Errorf("failed to create temp input file: %w", err)
 	}
 	defer os.Remove(inputFile.Name())
 
 	// Copy the uploaded file content to the temporary file
 	if _, err := io.Copy(inputFile, file); err != nil {
-		return fmt.Errorf("failed to copy to temp file: %w", err)
+		return nil, fmt.Errorf("failed to copy to temp file: %w", err)
 	}
 	inputFile.Close()
 
 	// Create a temporary file for the text output
 	outputFile, err := ioutil.TempFile("", "output-*.txt")
 	if err != nil {
-		return fmt.Errorf("failed to create temp output file: %w", err)
+		return nil, fmt.Errorf("failed to create temp output file: %w", err)
 	}
 	defer os.Remove(outputFile.Name())
 	outputFile.Close() // Close the file so pdftotext can write to it
@@ -50,27 +130,54 @@ func ProcessPDFChunks(file io.Reader, chunkSize int, overlap int, processChunk f
 	// The -layout flag helps preserve the document's structure.
 	cmd := exec.Command("pdftotext", "-layout", inputFile.Name(), outputFile.Name())
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("failed to run pdftotext command: %w. Ensure poppler-utils is installed", err)
+		return nil, fmt.Errorf("failed to run pdftotext command: %w. Ensure poppler-utils is installed", err)
 	}
 
 	// Read the entire text file content
 	textContent, err := ioutil.ReadFile(outputFile.Name())
 	if err != nil {
-		return fmt.Errorf("failed to read text output file: %w", err)
+		return nil, fmt.Errorf("failed to read text output file: %w", err)
 	}
 
-	// Use the existing rune-safe ChunkText function
-	chunks := ChunkText(string(textContent), chunkSize, overlap)
+	pages := fillMissingPagesWithOCR(string(textContent), inputFile.Name())
+	if strings.TrimSpace(strings.Join(pages, "")) == "" {
+		return nil, ErrNoText
+	}
+	return pages, nil
+}
 
-	// Process each chunk
-	for i, chunk := range chunks {
-		if err := processChunk(chunk, i); err != nil {
-			// If the callback returns an error, abort the processing and return the error.
-			return fmt.Errorf("failed to process chunk %d: %w", i, err)
+// fillMissingPagesWithOCR splits pdftotext output into pages and replaces the pages that
+// have no text layer with the OCR result of the rasterized page. pdftotext separates pages
+// with a form feed. A page failing OCR is left empty so the rest of the document is kept.
+func fillMissingPagesWithOCR(textContent string, pdfPath string) []string {
+	pages := strings.Split(textContent, "\f")
+	// pdftotext terminates the last page with a form feed as well.
+	if len(pages) > 1 && pages[len(pages)-1] == "" {
+		pages = pages[:len(pages)-1]
+	}
+
+	for i, page := range pages {
+		if strings.TrimSpace(page) != "" {
+			continue
+		}
+		log.Printf("Page %d has no text layer, running OCR", i+1)
+		ocrText, err := ocrPDFPage(pdfPath, i+1)
+		if err != nil {
+			log.Printf("Warning: skipping page %d, OCR failed: %v", i+1, err)
+			continue
 		}
+		pages[i] = ocrText
 	}
 
-	return nil
+	return pages
+}
+
+func extractTextFromHTML(file io.Reader) (string, error) {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	return stripHTML(string(data)), nil
 }
 
 func extractTextFromTXT(file io.Reader) (string, error) {
@@ -2,9 +2,10 @@ package storage
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"strategic-insight-analyst/backend/config"
 	"time"
 
@@ -35,28 +36,85 @@ func InitializeGCS() error {
 	return nil
 }
 
-func UploadFile(file multipart.File, header *multipart.FileHeader) (string, error) {
+// uploadTimeout bounds a single upload. It is generous because uploads are streamed
+// from the client and can be hundreds of megabytes.
+const uploadTimeout = 30 * time.Minute
+
+// UploadedObject describes a file written to the bucket.
+type UploadedObject struct {
+	ObjectName string
+	Size       int64
+	// SHA256 is the hex-encoded hash of the content, computed while streaming.
+	SHA256 string
+}
+
+// UploadStream streams r to a new object named after fileName, hashing the content on the way.
+// If reading r fails the upload is aborted and no object is created.
+func UploadStream(r io.Reader, fileName string) (UploadedObject, error) {
 	bucketName := config.AppConfig.GCSBucketName
 	if bucketName == "" {
-		return "", fmt.Errorf("GCS_BUCKET_NAME environment variable not set in config")
+		return UploadedObject{}, fmt.Errorf("GCS_BUCKET_NAME environment variable not set in config")
 	}
 
 	ctx := context.Background()
-	ctx, cancel := context.WithTimeout(ctx, time.Second*50)
+	ctx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	// Cancelling the context before Close aborts the upload.
 	defer cancel()
 
-	objectName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), header.Filename)
+	objectName := fmt.Sprintf("%d-%s", time.Now().UnixNano(), fileName)
 	wc := GCSClient.Bucket(bucketName).Object(objectName).NewWriter(ctx)
 
-	if _, err := io.Copy(wc, file); err != nil {
-		return "", fmt.Errorf("io.Copy: %v", err)
+	hasher := sha256.New()
+	size, err := io.Copy(io.MultiWriter(wc, hasher), r)
+	if err != nil {
+		return UploadedObject{}, fmt.Errorf("io.Copy: %w", err)
+	}
+
+	if err := wc.Close(); err != nil {
+		return UploadedObject{}, fmt.Errorf("Writer.Close: %v", err)
+	}
+
+	return UploadedObject{ObjectName: objectName, Size: size, SHA256: hex.EncodeToString(hasher.Sum(nil))}, nil
+}
+
+// WriteObject streams r to the given object name and returns the number of bytes written.
+// It is used for objects with a caller-defined layout, such as resumable upload parts.
+func WriteObject(objectName string, r io.Reader) (int64, error) {
+	bucketName := config.AppConfig.GCSBucketName
+	if bucketName == "" {
+		return 0, fmt.Errorf("GCS_BUCKET_NAME environment variable not set in config")
+	}
+
+	ctx := context.Background()
+	ctx, cancel := context.WithTimeout(ctx, uploadTimeout)
+	defer cancel()
+
+	wc := GCSClient.Bucket(bucketName).Object(objectName).NewWriter(ctx)
+	size, err := io.Copy(wc, r)
+	if err != nil {
+		return 0, fmt.Errorf("io.Copy: %w", err)
 	}
 
 	if err := wc.Close(); err != nil {
-		return "", fmt.Errorf("Writer.Close: %v", err)
+		return 0, fmt.Errorf("Writer.Close: %v", err)
 	}
+	return size, nil
+}
 
-	return objectName, nil
+// OpenFile returns a reader for the object's content. The caller must close it.
+// Unlike DownloadFile it doesn't buffer the object in memory or apply a timeout,
+// so it is suitable for processing large files.
+func OpenFile(objectName string) (io.ReadCloser, error) {
+	bucketName := config.AppConfig.GCSBucketName
+	if bucketName == "" {
+		return nil, fmt.Errorf("GCS_BUCKET_NAME environment variable not set in config")
+	}
+
+	rc, err := GCSClient.Bucket(bucketName).Object(objectName).NewReader(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("NewReader: %v", err)
+	}
+	return rc, nil
 }
 
 func DownloadFile(objectName string) ([]byte, error) {
@@ -3,12 +3,15 @@ package main
 import (
 	"log"
 	"net/http"
+	"time"
 
 	"strategic-insight-analyst/backend/config"
 	"strategic-insight-analyst/backend/database"
-	"strategic-insight-analyst/backend/firebase"
+	"strategic-insight-analyst/backend/internal/authn"
+	"strategic-insight-analyst/backend/internal/llm"
 	"strategic-insight-analyst/backend/internal/storage"
 	"strategic-insight-analyst/backend/routes"
+	"strategic-insight-analyst/backend/services"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
@@ -21,10 +24,17 @@ func main() {
 
 	database.Connect()
 	database.Migrate()
-	firebase.Initialize()
+	if err := authn.Initialize(); err != nil {
+		log.Fatal(err)
+	}
 	if err := storage.InitializeGCS(); err != nil {
 		log.Fatal(err)
 	}
+	if err := llm.Initialize(); err != nil {
+		log.Fatal(err)
+	}
+	services.StartResumableUploadCleanup(time.Hour)
+	services.StartGuestCleanup(time.Hour)
 
 	r := mux.NewRouter()
 	routes.RegisterRoutes(r)
@@ -32,8 +42,9 @@ func main() {
 	// CORS configuration
 	corsHandler := handlers.CORS(
 		handlers.AllowedOrigins([]string{config.AppConfig.FrontendURL}),
-		handlers.AllowedMethods([]string{"GET", "POST", "OPTIONS", "PUT", "DELETE"}),
-		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization"}),
+		handlers.AllowedMethods([]string{"GET", "HEAD", "POST", "OPTIONS", "PUT", "PATCH", "DELETE"}),
+		handlers.AllowedHeaders([]string{"X-Requested-With", "Content-Type", "Authorization", "Upload-Offset", "X-Workspace-ID"}),
+		handlers.ExposedHeaders([]string{"Location", "Upload-Offset", "Upload-Length", "Upload-Expires"}),
 	)
 
 	log.Println("Starting server on :8080")
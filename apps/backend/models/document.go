@@ -3,11 +3,39 @@ package models
 import "time"
 
 type Document struct {
-	ID              string    `json:"id"`
-	UserID          string    `json:"user_id"`
-	FileName        string    `json:"file_name"`
-	GCSPath         string    `json:"gcs_path"`
-	Status          string    `json:"status"` // e.g., "processing", "processed", "failed"
-	ProcessingError string    `json:"processingError,omitempty"`
-	CreatedAt       time.Time `json:"created_at"`
+	ID string `json:"id"`
+	// UserID is the uploader. It is empty for workspace documents whose uploader's account
+	// has been deleted.
+	UserID string `json:"user_id"`
+	// WorkspaceID is set for documents owned by a workspace. Other documents are in the
+	// personal space of UserID, their uploader.
+	WorkspaceID string `json:"workspace_id,omitempty"`
+	FileName    string `json:"file_name"`
+	Description string `json:"description,omitempty"`
+	// Metadata holds user-defined fields such as company or fiscal year. Values are
+	// strings, numbers or booleans.
+	Metadata        map[string]any `json:"metadata"`
+	GCSPath         string         `json:"gcs_path"`
+	Status          string         `json:"status"` // e.g., "processing", "processed", "failed"
+	ProcessingError string         `json:"processingError,omitempty"`
+	// ContentType is the type detected from the file's content at upload.
+	ContentType string `json:"content_type,omitempty"`
+	SizeBytes   int64  `json:"size_bytes"`
+	// ContentHash is the hex SHA-256 of the file's content.
+	ContentHash string `json:"content_hash,omitempty"`
+	// ParentDocumentID is set for documents extracted from another one, such as email attachments.
+	ParentDocumentID string `json:"parent_document_id,omitempty"`
+	// BatchID and FolderPath are set for documents extracted from a ZIP upload.
+	BatchID    string `json:"batch_id,omitempty"`
+	FolderPath string `json:"folder_path,omitempty"`
+	// VersionGroupID is the ID of the document's first version, shared by all its versions.
+	VersionGroupID string `json:"version_group_id"`
+	Version        int    `json:"version"`
+	// CollectionIDs and TagIDs list the collections and tags of the document. They are
+	// shared by all its versions.
+	CollectionIDs []string `json:"collection_ids"`
+	TagIDs        []string `json:"tag_ids"`
+	// SourceURL is set for documents imported from the web.
+	SourceURL string    `json:"source_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
 }
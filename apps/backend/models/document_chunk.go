@@ -12,5 +12,7 @@ type DocumentChunk struct {
 	ChunkIndex int             `json:"chunk_index"`
 	Content    string          `json:"content"`
 	Embedding  pgvector.Vector `json:"embedding,omitempty"`
-	CreatedAt  time.Time       `json:"created_at"`
+	// Metadata holds extractor-specific fields, e.g. From/To/Date/Subject for emails.
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ResumableUpload is an upload sent in byte ranges that can be resumed after a failure.
+type ResumableUpload struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	FileName    string    `json:"file_name"`
+	FileType    string    `json:"file_type,omitempty"` // detected from the first bytes received
+	SizeBytes   int64     `json:"size_bytes"`
+	OffsetBytes int64     `json:"offset_bytes"`
+	Status      string    `json:"status"` // "open", or "finalizing" once finalize was called
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Share grants a user access to another user's document or collection, including the
+// collection's subcollections. Name is the document's file name or the collection's name,
+// and Permission is "viewer" or "chatter".
+type Share struct {
+	ID             string    `json:"id"`
+	OwnerID        string    `json:"owner_id"`
+	OwnerEmail     string    `json:"owner_email"`
+	RecipientID    string    `json:"recipient_id"`
+	RecipientEmail string    `json:"recipient_email"`
+	DocumentID     string    `json:"document_id,omitempty"`
+	CollectionID   string    `json:"collection_id,omitempty"`
+	Name           string    `json:"name"`
+	Permission     string    `json:"permission"`
+	CreatedAt      time.Time `json:"created_at"`
+}
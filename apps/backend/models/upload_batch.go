@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UploadBatch groups the documents extracted from a single ZIP upload. Status is
+// "extracting" until the documents have been created from the archive, then "extracted",
+// or "failed" with Error, in which case the batch has no documents.
+type UploadBatch struct {
+	ID           string        `json:"id"`
+	UserID       string        `json:"user_id"`
+	FileName     string        `json:"file_name"`
+	Status       string        `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	Total        int           `json:"total"`
+	Processing   int           `json:"processing"`
+	Processed    int           `json:"processed"`
+	Failed       int           `json:"failed"`
+	SkippedFiles []SkippedFile `json:"skipped_files"`
+	Documents    []Document    `json:"documents"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+// SkippedFile is an archive entry that was not ingested, with the reason why.
+type SkippedFile struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
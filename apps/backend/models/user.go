@@ -2,10 +2,14 @@ package models
 
 import "time"
 
+// User is a registered user. Email is empty for guests and users of sign-in methods without
+// one; ExpiresAt is when a guest account is deleted unless upgraded.
 type User struct {
-	ID         string    `json:"id"`
-	Email      string    `json:"email"`
-	Password   string    `json:"-"` // Omit from JSON responses
-	AuthMethod string    `json:"auth_method"`
-	CreatedAt  time.Time `json:"created_at"`
+	ID         string     `json:"id"`
+	Email      string     `json:"email"`
+	Password   string     `json:"-"` // Omit from JSON responses
+	AuthMethod string     `json:"auth_method"`
+	Groups     []string   `json:"groups"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
 }
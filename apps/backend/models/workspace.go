@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// Workspace is a team space owning documents. Role is the caller's role in it.
+type Workspace struct {
+	ID        string            `json:"id"`
+	Name      string            `json:"name"`
+	Role      string            `json:"role"`
+	Members   []WorkspaceMember `json:"members,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// WorkspaceMember is a user's membership in a workspace. Role is "owner", "editor" or
+// "viewer".
+type WorkspaceMember struct {
+	WorkspaceID string    `json:"workspace_id"`
+	UserID      string    `json:"user_id"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	JoinedAt    time.Time `json:"joined_at"`
+}
+
+// WorkspaceInvitation invites the user with Email to join a workspace with Role.
+type WorkspaceInvitation struct {
+	ID            string    `json:"id"`
+	WorkspaceID   string    `json:"workspace_id"`
+	WorkspaceName string    `json:"workspace_name"`
+	Email         string    `json:"email"`
+	Role          string    `json:"role"`
+	InvitedBy     string    `json:"invited_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
@@ -15,10 +15,57 @@ func RegisterRoutes(r *mux.Router) {
 	protected.Use(api.AuthMiddleware)
 	protected.HandleFunc("/protected", handlers.ProtectedHandler).Methods("GET")
 	protected.HandleFunc("/documents/upload", handlers.UploadDocumentHandler).Methods("POST")
+	protected.HandleFunc("/documents/import-url", handlers.ImportURLHandler).Methods("POST")
+	protected.HandleFunc("/uploads", handlers.CreateResumableUploadHandler).Methods("POST")
+	protected.HandleFunc("/uploads/{upload_id}", handlers.GetResumableUploadHandler).Methods("HEAD", "GET")
+	protected.HandleFunc("/uploads/{upload_id}", handlers.PatchResumableUploadHandler).Methods("PATCH")
+	protected.HandleFunc("/uploads/{upload_id}", handlers.DeleteResumableUploadHandler).Methods("DELETE")
+	protected.HandleFunc("/uploads/{upload_id}/finalize", handlers.FinalizeResumableUploadHandler).Methods("POST")
 	protected.HandleFunc("/documents", handlers.GetDocumentsHandler).Methods("GET")
 	protected.HandleFunc("/documents/download/{document_id}", handlers.DownloadDocumentHandler).Methods("GET")
+	protected.HandleFunc("/documents/batches/{batch_id}", handlers.GetUploadBatchHandler).Methods("GET")
 	protected.HandleFunc("/documents/{document_id}/status", handlers.GetDocumentStatusHandler).Methods("GET")
+	protected.HandleFunc("/documents/{document_id}/versions", handlers.UploadDocumentVersionHandler).Methods("POST")
+	protected.HandleFunc("/documents/{document_id}/versions", handlers.GetDocumentVersionsHandler).Methods("GET")
+	protected.HandleFunc("/documents/{document_id}/diff", handlers.DiffDocumentVersionsHandler).Methods("GET")
+	protected.HandleFunc("/documents/{document_id}/tags/{tag_id}", handlers.AddDocumentTagHandler).Methods("PUT")
+	protected.HandleFunc("/documents/{document_id}/tags/{tag_id}", handlers.RemoveDocumentTagHandler).Methods("DELETE")
+	protected.HandleFunc("/documents/{document_id}", handlers.UpdateDocumentHandler).Methods("PATCH")
 	protected.HandleFunc("/documents/{document_id}", handlers.DeleteDocumentHandler).Methods("DELETE")
+	protected.HandleFunc("/collections", handlers.GetCollectionsHandler).Methods("GET")
+	protected.HandleFunc("/collections", handlers.CreateCollectionHandler).Methods("POST")
+	protected.HandleFunc("/collections/{collection_id}", handlers.GetCollectionHandler).Methods("GET")
+	protected.HandleFunc("/collections/{collection_id}", handlers.UpdateCollectionHandler).Methods("PATCH")
+	protected.HandleFunc("/collections/{collection_id}", handlers.DeleteCollectionHandler).Methods("DELETE")
+	protected.HandleFunc("/collections/{collection_id}/documents/{document_id}", handlers.AddDocumentToCollectionHandler).Methods("PUT")
+	protected.HandleFunc("/collections/{collection_id}/documents/{document_id}", handlers.RemoveDocumentFromCollectionHandler).Methods("DELETE")
+	protected.HandleFunc("/tags", handlers.GetTagsHandler).Methods("GET")
+	protected.HandleFunc("/tags", handlers.CreateTagHandler).Methods("POST")
+	protected.HandleFunc("/tags/{tag_id}", handlers.UpdateTagHandler).Methods("PATCH")
+	protected.HandleFunc("/tags/{tag_id}", handlers.DeleteTagHandler).Methods("DELETE")
+	protected.HandleFunc("/shares", handlers.GetSharesHandler).Methods("GET")
+	protected.HandleFunc("/shares", handlers.CreateShareHandler).Methods("POST")
+	protected.HandleFunc("/shares/received", handlers.GetReceivedSharesHandler).Methods("GET")
+	protected.HandleFunc("/shares/{share_id}", handlers.UpdateShareHandler).Methods("PATCH")
+	protected.HandleFunc("/shares/{share_id}", handlers.DeleteShareHandler).Methods("DELETE")
+	protected.HandleFunc("/workspaces", handlers.GetWorkspacesHandler).Methods("GET")
+	protected.HandleFunc("/workspaces", handlers.CreateWorkspaceHandler).Methods("POST")
+	protected.HandleFunc("/workspaces/{workspace_id}", handlers.GetWorkspaceHandler).Methods("GET")
+	protected.HandleFunc("/workspaces/{workspace_id}", handlers.UpdateWorkspaceHandler).Methods("PATCH")
+	protected.HandleFunc("/workspaces/{workspace_id}", handlers.DeleteWorkspaceHandler).Methods("DELETE")
+	protected.HandleFunc("/workspaces/{workspace_id}/members/{user_id}", handlers.UpdateWorkspaceMemberHandler).Methods("PATCH")
+	protected.HandleFunc("/workspaces/{workspace_id}/members/{user_id}", handlers.RemoveWorkspaceMemberHandler).Methods("DELETE")
+	protected.HandleFunc("/workspaces/{workspace_id}/invitations", handlers.GetWorkspaceInvitationsHandler).Methods("GET")
+	protected.HandleFunc("/workspaces/{workspace_id}/invitations", handlers.CreateWorkspaceInvitationHandler).Methods("POST")
+	protected.HandleFunc("/workspaces/{workspace_id}/invitations/{invitation_id}", handlers.DeleteWorkspaceInvitationHandler).Methods("DELETE")
+	protected.HandleFunc("/invitations", handlers.GetInvitationsHandler).Methods("GET")
+	protected.HandleFunc("/invitations/{invitation_id}/accept", handlers.AcceptInvitationHandler).Methods("POST")
+	protected.HandleFunc("/invitations/{invitation_id}", handlers.DeclineInvitationHandler).Methods("DELETE")
+	protected.HandleFunc("/search", handlers.SearchHandler).Methods("GET")
 	protected.HandleFunc("/chat", handlers.ChatHandler).Methods("POST")
 	protected.HandleFunc("/chat/{document_id}", handlers.GetChatHistoryHandler).Methods("GET")
+	protected.HandleFunc("/embeddings/cache/stats", handlers.GetEmbeddingCacheStatsHandler).Methods("GET")
+	protected.HandleFunc("/api-keys", handlers.GetAPIKeysHandler).Methods("GET")
+	protected.HandleFunc("/api-keys", handlers.CreateAPIKeyHandler).Methods("POST")
+	protected.HandleFunc("/api-keys/{key_id}", handlers.DeleteAPIKeyHandler).Methods("DELETE")
 }
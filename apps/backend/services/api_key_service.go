@@ -0,0 +1,169 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrInvalidAPIKey is returned for API key changes that fail validation.
+	ErrInvalidAPIKey = errors.New("invalid API key")
+	// ErrAPIKeyRejected is returned when authenticating with an unknown or expired key.
+	ErrAPIKeyRejected = errors.New("unknown or expired API key")
+)
+
+// API key scopes. A key may only be used for requests covered by one of its scopes.
+const (
+	// ScopeRead allows all reading requests, e.g. listing, downloading and searching.
+	ScopeRead = "read"
+	// ScopeUpload allows uploading and importing documents and their new versions.
+	ScopeUpload = "upload"
+	// ScopeChat allows asking questions about documents.
+	ScopeChat = "chat"
+	// ScopeWrite allows every other change, e.g. organising, sharing and deleting documents.
+	ScopeWrite = "write"
+)
+
+var apiKeyScopes = []string{ScopeRead, ScopeUpload, ScopeChat, ScopeWrite}
+
+// APIKeyPrefix starts every API key, which tells them apart from ID tokens.
+const APIKeyPrefix = "sia_"
+
+const (
+	maxAPIKeyNameLength = 255
+	// apiKeyDisplayLength is the length of the start of a key kept to tell keys apart.
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+	// apiKeyUseGranularity limits how often a key's last use is written.
+	apiKeyUseGranularity = time.Minute
+)
+
+const apiKeyColumns = `id, user_id, name, prefix, scopes, expires_at, last_used_at, created_at`
+
+func scanAPIKey(row rowScanner) (models.APIKey, error) {
+	var key models.APIKey
+	var expiresAt, lastUsedAt sql.NullTime
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &expiresAt, &lastUsedAt, &key.CreatedAt)
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	return key, err
+}
+
+// CreateAPIKey creates an API key for the user with the scopes, or all scopes if none are
+// given. The returned key is the only time its secret is available.
+func CreateAPIKey(userID, name string, scopes []string, expiresAt *time.Time) (models.APIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxAPIKeyNameLength {
+		return models.APIKey{}, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidAPIKey, maxAPIKeyNameLength)
+	}
+	if len(scopes) == 0 {
+		scopes = slices.Clone(apiKeyScopes)
+	}
+	for _, scope := range scopes {
+		if !slices.Contains(apiKeyScopes, scope) {
+			return models.APIKey{}, fmt.Errorf("%w: scope must be one of %s", ErrInvalidAPIKey, strings.Join(apiKeyScopes, ", "))
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return models.APIKey{}, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIKey{}, err
+	}
+	plain := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	query := `
+		INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING ` + apiKeyColumns
+	key, err := scanAPIKey(database.DB.QueryRow(query, uuid.NewV4().String(), userID, name,
+		plain[:apiKeyDisplayLength], hashAPIKey(plain), pq.Array(scopes), expiresAt))
+	if err != nil {
+		return models.APIKey{}, err
+	}
+	key.Key = plain
+	return key, nil
+}
+
+// GetUserAPIKeys lists the user's API keys, including expired ones.
+func GetUserAPIKeys(userID string) ([]models.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]models.APIKey, 0)
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey deletes the user's API key, which stops working immediately. It returns
+// sql.ErrNoRows if the user has no such key.
+func RevokeAPIKey(keyID, userID string) error {
+	result, err := database.DB.Exec(`DELETE FROM api_keys WHERE id = $1 AND user_id = $2`, keyID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
+
+// AuthenticateAPIKey returns the API key with the secret, recording its use, or
+// ErrAPIKeyRejected if there is no such key or it has expired.
+func AuthenticateAPIKey(plain string) (models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + ` FROM api_keys
+		WHERE key_hash = $1 AND (expires_at IS NULL OR expires_at > now())
+	`
+	key, err := scanAPIKey(database.DB.QueryRow(query, hashAPIKey(plain)))
+	if err == sql.ErrNoRows {
+		return models.APIKey{}, ErrAPIKeyRejected
+	} else if err != nil {
+		return models.APIKey{}, err
+	}
+
+	if key.LastUsedAt == nil || time.Since(*key.LastUsedAt) > apiKeyUseGranularity {
+		if _, err := database.DB.Exec(`UPDATE api_keys SET last_used_at = now() WHERE id = $1`, key.ID); err != nil {
+			return models.APIKey{}, err
+		}
+	}
+	return key, nil
+}
+
+// APIKeyHasScope reports whether the key may be used for requests needing the scope.
+func APIKeyHasScope(key models.APIKey, scope string) bool {
+	return slices.Contains(key.Scopes, scope)
+}
+
+// hashAPIKey hashes a key for storage. Keys are random, so a fast unsalted hash suffices.
+func hashAPIKey(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+)
+
+var (
+	// ErrDocumentNotFound is returned by document-scoped operations when the document doesn't
+	// exist or the caller may not access it. The two cases are deliberately
+	// indistinguishable so document IDs of other users can't be probed.
+	ErrDocumentNotFound = errors.New("document not found")
+	// ErrPermissionDenied is returned when the caller may access a document or workspace, but
+	// not in the way the operation needs, e.g. a viewer trying to chat.
+	ErrPermissionDenied = errors.New("you don't have permission to do this")
+)
+
+// accessLevel is a level of access to a document. Each level includes the ones below it.
+type accessLevel int
+
+const (
+	accessNone accessLevel = iota
+	// accessView allows reading a document: listing, downloading, searching and diffing.
+	accessView
+	// accessChat additionally allows chatting with a document and using it as chat context.
+	accessChat
+	// accessOwner additionally allows changing, deleting, organising and sharing a document.
+	accessOwner
+)
+
+// Share permissions and the access they grant.
+const (
+	PermissionViewer  = "viewer"
+	PermissionChatter = "chatter"
+)
+
+var sharePermissionAccess = map[string]accessLevel{
+	PermissionViewer:  accessView,
+	PermissionChatter: accessChat,
+}
+
+// Workspace roles. Owners and editors have full access to the workspace's documents and
+// viewers may read and chat with them; only owners manage the workspace itself.
+const (
+	RoleOwner  = "owner"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var workspaceRoleAccess = map[string]accessLevel{
+	RoleOwner:  accessOwner,
+	RoleEditor: accessOwner,
+	RoleViewer: accessChat,
+}
+
+// authorizeDocument is the access check every document-scoped operation goes through. It
+// returns the document if userID may access it at level need, ErrPermissionDenied if the
+// user has lower access, and ErrDocumentNotFound if none. Access to a workspace's documents
+// comes from the user's role in it; personal documents are the uploader's and can be shared.
+func authorizeDocument(documentID, userID string, need accessLevel) (models.Document, error) {
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE id = $1`
+	doc, err := scanDocument(database.DB.QueryRow(query, documentID))
+	if err == sql.ErrNoRows {
+		return models.Document{}, ErrDocumentNotFound
+	} else if err != nil {
+		return models.Document{}, err
+	}
+
+	level := accessOwner
+	switch {
+	case doc.WorkspaceID != "":
+		level, err = workspaceAccess(doc.WorkspaceID, userID)
+	case doc.UserID != userID:
+		level, err = sharedAccess(doc.VersionGroupID, userID)
+	}
+	if err != nil {
+		return models.Document{}, err
+	}
+	switch {
+	case level == accessNone:
+		return models.Document{}, ErrDocumentNotFound
+	case level < need:
+		return models.Document{}, ErrPermissionDenied
+	}
+	return doc, nil
+}
+
+// authorizeOwner is the access check of resources only the user who created them may use,
+// such as upload batches and resumable uploads: the row id of table must belong to userID.
+// Like authorizeDocument, it doesn't tell other users' rows from missing ones; both are
+// sql.ErrNoRows.
+func authorizeOwner(table, id, userID string) error {
+	var ownerID string
+	if err := database.DB.QueryRow(`SELECT user_id FROM `+table+` WHERE id = $1`, id).Scan(&ownerID); err != nil {
+		return err
+	}
+	if ownerID != userID {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// authorizeDocuments is authorizeDocument for several documents. It fails unless userID may
+// access all of them at level need.
+func authorizeDocuments(documentIDs []string, userID string, need accessLevel) ([]models.Document, error) {
+	documents := make([]models.Document, 0, len(documentIDs))
+	for _, id := range documentIDs {
+		doc, err := authorizeDocument(id, userID, need)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, doc)
+	}
+	return documents, nil
+}
+
+// sharedAccess returns the highest access the shares of userID grant to the document group,
+// directly or through a collection containing the document or one of its ancestors.
+func sharedAccess(groupID, userID string) (accessLevel, error) {
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT collection_id AS id FROM document_collections WHERE document_id = $2
+			UNION
+			SELECT c.parent_id FROM collections c JOIN ancestors a ON c.id = a.id WHERE c.parent_id IS NOT NULL
+		)
+		SELECT permission FROM shares
+		WHERE recipient_id = $1 AND (document_id = $2 OR collection_id IN (SELECT id FROM ancestors))
+	`
+	rows, err := database.DB.Query(query, userID, groupID)
+	if err != nil {
+		return accessNone, err
+	}
+	defer rows.Close()
+
+	level := accessNone
+	for rows.Next() {
+		var permission string
+		if err := rows.Scan(&permission); err != nil {
+			return accessNone, err
+		}
+		level = max(level, sharePermissionAccess[permission])
+	}
+	return level, rows.Err()
+}
+
+// workspaceAccess returns the access the user's role in the workspace grants to its
+// documents.
+func workspaceAccess(workspaceID, userID string) (accessLevel, error) {
+	var role string
+	query := `SELECT role FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+	err := database.DB.QueryRow(query, workspaceID, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return accessNone, nil
+	} else if err != nil {
+		return accessNone, err
+	}
+	return workspaceRoleAccess[role], nil
+}
+
+// accessibleCondition is the set-based form of authorizeDocument for queries over many
+// documents: an SQL condition on the documents table aliased as alias that holds when the
+// user passed as $1 may access the document at level need.
+func accessibleCondition(alias string, need accessLevel) string {
+	personal := alias + ".user_id = $1"
+	if need < accessOwner {
+		permissions := sharePermissionsGranting(need)
+		personal = fmt.Sprintf(`(%[1]s.user_id = $1 OR %[1]s.version_group_id IN (
+			SELECT document_id FROM shares
+			WHERE recipient_id = $1 AND document_id IS NOT NULL AND permission IN (%[2]s)
+			UNION
+			SELECT document_id FROM document_collections WHERE collection_id IN (%[3]s)
+		))`, alias, permissions, sharedCollectionsQuery(permissions))
+	}
+	return fmt.Sprintf(`((%[1]s.workspace_id IS NULL AND %[2]s) OR %[1]s.workspace_id IN (
+		SELECT workspace_id FROM workspace_members WHERE user_id = $1 AND role IN (%[3]s)
+	))`, alias, personal, literalsGranting(workspaceRoleAccess, need))
+}
+
+// sharedCollectionsQuery selects the IDs of the collections shared with the user passed as
+// $1 with one of permissions, and of all their subcollections.
+func sharedCollectionsQuery(permissions string) string {
+	return fmt.Sprintf(`
+		WITH RECURSIVE shared AS (
+			SELECT collection_id AS id FROM shares
+			WHERE recipient_id = $1 AND collection_id IS NOT NULL AND permission IN (%s)
+			UNION
+			SELECT c.id FROM collections c JOIN shared ON c.parent_id = shared.id
+		)
+		SELECT id FROM shared`, permissions)
+}
+
+// sharePermissionsGranting returns the share permissions granting at least level need as
+// an SQL list of literals.
+func sharePermissionsGranting(need accessLevel) string {
+	return literalsGranting(sharePermissionAccess, need)
+}
+
+// literalsGranting returns the keys of levels granting at least level need as an SQL list
+// of literals. At least one key must qualify.
+func literalsGranting(levels map[string]accessLevel, need accessLevel) string {
+	var literals []string
+	for name, level := range levels {
+		if level >= need {
+			literals = append(literals, "'"+name+"'")
+		}
+	}
+	sort.Strings(literals)
+	return strings.Join(literals, ", ")
+}
@@ -0,0 +1,323 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/internal/processor"
+	"strategic-insight-analyst/backend/internal/storage"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrInvalidArchive is returned for ZIP uploads that can't be read or exceed the limits.
+	ErrInvalidArchive = errors.New("invalid ZIP archive")
+)
+
+// Limits guarding against zip bombs. An entry over maxArchiveEntrySize is skipped, while
+// an archive over maxArchiveEntries or maxArchiveTotalSize, all entries' uncompressed size
+// together, is rejected. Sizes are counted as the entries are read, as the sizes in the
+// archive's headers can't be trusted.
+const (
+	maxArchiveEntrySize = 100 << 20 // 100 MB
+	maxArchiveTotalSize = 2 << 30   // 2 GB
+	maxArchiveEntries   = 1000
+)
+
+// Upload batch statuses. Entries are extracted in the background, after which the batch's
+// documents exist and are processed one after another.
+const (
+	batchExtracting = "extracting"
+	batchExtracted  = "extracted"
+	batchFailed     = "failed"
+)
+
+type archiveEntry struct {
+	doc      models.Document
+	fileType string
+}
+
+// ProcessZipUpload checks an archive that has been stored in GCS and queues it to be fanned
+// out into one document per supported file, preserving the folder path. The returned batch
+// is "extracting"; its documents appear once extraction is done, and unsupported entries
+// are reported on it as skipped. The archive itself is deleted afterwards. The documents
+// are added to the workspace, or the user's personal space when workspaceID is empty.
+func ProcessZipUpload(object storage.UploadedObject, fileName string, userID, workspaceID string) (models.UploadBatch, error) {
+	batch, archiveFile, reader, err := queueZipUpload(object, fileName, userID, workspaceID)
+	if err != nil {
+		if deleteErr := storage.DeleteFile(object.ObjectName); deleteErr != nil {
+			log.Printf("Warning: failed to delete ZIP archive %s after a failed upload: %v", object.ObjectName, deleteErr)
+		}
+		return models.UploadBatch{}, err
+	}
+
+	go func() {
+		defer os.Remove(archiveFile.Name())
+		defer archiveFile.Close()
+		extractZipBatch(batch, reader, workspaceID)
+		if err := storage.DeleteFile(object.ObjectName); err != nil {
+			log.Printf("Warning: failed to delete ZIP archive %s after extraction: %v", object.ObjectName, err)
+		}
+	}()
+	return batch, nil
+}
+
+// queueZipUpload opens the archive, checks its limits and creates the batch. On success the
+// caller owns the spooled archive file.
+func queueZipUpload(object storage.UploadedObject, fileName string, userID, workspaceID string) (models.UploadBatch, *os.File, *zip.Reader, error) {
+	if err := authorizeUpload(workspaceID, userID); err != nil {
+		return models.UploadBatch{}, nil, nil, err
+	}
+
+	// archive/zip needs random access, so the archive is spooled to a temporary file.
+	archiveFile, err := downloadToTempFile(object.ObjectName)
+	if err != nil {
+		return models.UploadBatch{}, nil, nil, err
+	}
+	fail := func(err error) (models.UploadBatch, *os.File, *zip.Reader, error) {
+		archiveFile.Close()
+		os.Remove(archiveFile.Name())
+		return models.UploadBatch{}, nil, nil, err
+	}
+
+	reader, err := zip.NewReader(archiveFile, object.Size)
+	if err != nil {
+		return fail(fmt.Errorf("%w: %v", ErrInvalidArchive, err))
+	}
+	if len(reader.File) > maxArchiveEntries {
+		return fail(fmt.Errorf("%w: the archive has more than %d entries", ErrInvalidArchive, maxArchiveEntries))
+	}
+	var declaredSize uint64
+	for _, f := range reader.File {
+		declaredSize += min(f.UncompressedSize64, maxArchiveEntrySize)
+	}
+	if declaredSize > maxArchiveTotalSize {
+		return fail(errArchiveTooLarge())
+	}
+
+	batch := models.UploadBatch{
+		ID:           uuid.NewV4().String(),
+		UserID:       userID,
+		FileName:     fileName,
+		Status:       batchExtracting,
+		SkippedFiles: make([]models.SkippedFile, 0),
+		Documents:    make([]models.Document, 0),
+		CreatedAt:    time.Now(),
+	}
+	query := `INSERT INTO upload_batches (id, user_id, file_name, status, created_at) VALUES ($1, $2, $3, $4, $5)`
+	if _, err := database.DB.Exec(query, batch.ID, batch.UserID, batch.FileName, batch.Status, batch.CreatedAt); err != nil {
+		return fail(fmt.Errorf("failed to create upload batch: %w", err))
+	}
+	return batch, archiveFile, reader, nil
+}
+
+func errArchiveTooLarge() error {
+	return fmt.Errorf("%w: the archive's files exceed %d MB in total", ErrInvalidArchive, maxArchiveTotalSize>>20)
+}
+
+// extractZipBatch stores the archive's supported entries and creates their documents, then
+// processes them. If anything fails, the stored entries and created documents are removed
+// again and the batch is marked failed.
+func extractZipBatch(batch models.UploadBatch, reader *zip.Reader, workspaceID string) {
+	var entries []archiveEntry
+	fail := func(cause error) {
+		log.Printf("ERROR: Failed to extract upload batch %s: %v", batch.ID, cause)
+		if _, err := database.DB.Exec(`DELETE FROM documents WHERE batch_id = $1`, batch.ID); err != nil {
+			log.Printf("Warning: failed to delete the documents of failed upload batch %s: %v", batch.ID, err)
+		}
+		for _, entry := range entries {
+			if err := storage.DeleteFile(entry.doc.GCSPath); err != nil {
+				log.Printf("Warning: failed to delete %s after a failed ZIP upload: %v", entry.doc.GCSPath, err)
+			}
+		}
+		query := `UPDATE upload_batches SET status = $2, error = $3 WHERE id = $1`
+		if _, err := database.DB.Exec(query, batch.ID, batchFailed, cause.Error()); err != nil {
+			log.Printf("ERROR: Failed to mark upload batch %s as failed: %v", batch.ID, err)
+		}
+	}
+
+	remaining := int64(maxArchiveTotalSize)
+	for _, f := range reader.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+
+		entry, reason, err := storeArchiveEntry(f, batch.UserID, workspaceID, batch.ID, &remaining)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if reason != "" {
+			batch.SkippedFiles = append(batch.SkippedFiles, models.SkippedFile{Path: f.Name, Reason: reason})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		fail(fmt.Errorf("%w: the archive contains no supported files", ErrInvalidArchive))
+		return
+	}
+
+	for i := range entries {
+		doc, err := createDocumentRecord(entries[i].doc)
+		if err != nil {
+			fail(err)
+			return
+		}
+		entries[i].doc = doc
+	}
+
+	skippedJSON, err := json.Marshal(batch.SkippedFiles)
+	if err != nil {
+		fail(fmt.Errorf("failed to marshal skipped files: %w", err))
+		return
+	}
+	query := `UPDATE upload_batches SET status = $2, skipped_files = $3 WHERE id = $1`
+	if _, err := database.DB.Exec(query, batch.ID, batchExtracted, string(skippedJSON)); err != nil {
+		fail(fmt.Errorf("failed to update upload batch: %w", err))
+		return
+	}
+
+	// Documents are processed one after another so a large archive doesn't flood the embedding API.
+	for _, entry := range entries {
+		processStoredDocument(entry.doc, entry.fileType)
+	}
+	log.Printf("Finished processing upload batch %s", batch.ID)
+}
+
+// storeArchiveEntry streams a supported entry to GCS. For entries that are skipped it
+// returns the reason instead. remaining is what is left of the archive's total size limit;
+// exceeding it fails the whole archive.
+func storeArchiveEntry(f *zip.File, userID, workspaceID, batchID string, remaining *int64) (archiveEntry, string, error) {
+	fileName := path.Base(f.Name)
+	folderPath := path.Dir(f.Name)
+	if folderPath == "." {
+		folderPath = ""
+	}
+
+	// Finder and Explorer metadata that ends up in archives.
+	if strings.HasPrefix(fileName, ".") || strings.HasPrefix(folderPath, "__MACOSX") || fileName == "Thumbs.db" {
+		return archiveEntry{}, "hidden or system file", nil
+	}
+	if f.UncompressedSize64 > maxArchiveEntrySize {
+		return archiveEntry{}, fmt.Sprintf("file exceeds the %d MB per-file limit", maxArchiveEntrySize>>20), nil
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return archiveEntry{}, fmt.Sprintf("failed to open archive entry: %v", err), nil
+	}
+	defer rc.Close()
+
+	br := bufio.NewReaderSize(rc, processor.SniffLen)
+	head, err := br.Peek(processor.SniffLen)
+	if err != nil && err != io.EOF {
+		return archiveEntry{}, fmt.Sprintf("failed to read archive entry: %v", err), nil
+	}
+	fileType, err := processor.DetectFileType(head, "", fileName)
+	if err != nil {
+		return archiveEntry{}, err.Error(), nil
+	}
+	if !processor.IsSupportedFileType(fileType) {
+		// Nested archives end up here.
+		return archiveEntry{}, "unsupported file type", nil
+	}
+
+	// The declared size can't be trusted, so cap what is actually read as well.
+	limit := min(maxArchiveEntrySize, *remaining)
+	object, err := storage.UploadStream(io.LimitReader(br, limit+1), fileName)
+	if err != nil {
+		return archiveEntry{}, "", fmt.Errorf("failed to upload %s to GCS: %w", f.Name, err)
+	}
+	if object.Size > limit {
+		if err := storage.DeleteFile(object.ObjectName); err != nil {
+			log.Printf("Warning: failed to delete oversized archive entry %s: %v", object.ObjectName, err)
+		}
+		if object.Size > *remaining {
+			return archiveEntry{}, "", errArchiveTooLarge()
+		}
+		return archiveEntry{}, fmt.Sprintf("file exceeds the %d MB per-file limit", maxArchiveEntrySize>>20), nil
+	}
+	*remaining -= object.Size
+
+	doc := newDocument(userID, fileName, fileType, object)
+	doc.WorkspaceID = workspaceID
+	doc.FolderPath = folderPath
+	doc.BatchID = batchID
+	return archiveEntry{doc: doc, fileType: fileType}, "", nil
+}
+
+func downloadToTempFile(objectName string) (*os.File, error) {
+	rc, err := storage.OpenFile(objectName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stored archive: %w", err)
+	}
+	defer rc.Close()
+
+	tmp, err := os.CreateTemp("", "archive-*.zip")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	if _, err := io.Copy(tmp, rc); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, fmt.Errorf("failed to download stored archive: %w", err)
+	}
+	return tmp, nil
+}
+
+// GetUploadBatch returns the batch with its documents and aggregate processing progress.
+func GetUploadBatch(batchID, userID string) (models.UploadBatch, error) {
+	batch := models.UploadBatch{Documents: make([]models.Document, 0)}
+	var skippedJSON []byte
+	var batchError sql.NullString
+	if err := authorizeOwner("upload_batches", batchID, userID); err != nil {
+		return models.UploadBatch{}, err
+	}
+	query := `SELECT id, user_id, file_name, status, error, skipped_files, created_at FROM upload_batches WHERE id = $1`
+	err := database.DB.QueryRow(query, batchID).Scan(&batch.ID, &batch.UserID, &batch.FileName, &batch.Status, &batchError, &skippedJSON, &batch.CreatedAt)
+	if err != nil {
+		return models.UploadBatch{}, err
+	}
+	batch.Error = batchError.String
+	if err := json.Unmarshal(skippedJSON, &batch.SkippedFiles); err != nil {
+		return models.UploadBatch{}, fmt.Errorf("failed to unmarshal skipped files: %w", err)
+	}
+
+	rows, err := database.DB.Query(`SELECT `+documentColumns+` FROM documents WHERE batch_id = $1 ORDER BY folder_path, file_name`, batchID)
+	if err != nil {
+		return models.UploadBatch{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		doc, err := scanDocument(rows)
+		if err != nil {
+			return models.UploadBatch{}, err
+		}
+		switch doc.Status {
+		case "processed":
+			batch.Processed++
+		case "failed":
+			batch.Failed++
+		default:
+			batch.Processing++
+		}
+		batch.Documents = append(batch.Documents, doc)
+	}
+	batch.Total = len(batch.Documents)
+
+	return batch, nil
+}
@@ -1,9 +1,12 @@
 package services
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
 	"sort"
 	"strategic-insight-analyst/backend/database"
 	"strategic-insight-analyst/backend/internal/llm"
@@ -16,24 +19,48 @@ import (
 	"google.golang.org/genai"
 )
 
-func SaveUserMessage(documentID, userID, userMessage string, attachedDocs []models.Document) (models.ChatMessage, error) {
-	var attachedDocsJSON []byte
-	var err error
+// ChatSession is a chat turn about one version of a document. StartChat authorizes it once;
+// its methods act on the documents it holds without checking access again.
+type ChatSession struct {
+	// Document is the version the answer comes from. The conversation belongs to the
+	// document as a whole and is shared by all its versions.
+	Document models.Document
+	// Attached are the documents attached to the message, whose content is sent in full.
+	Attached []models.Document
+	userID   string
+}
+
+// StartChat returns the session of userID chatting with the given version of the document
+// documentID belongs to, or its latest version if version is 0, and with the attached
+// documents. It fails unless the user may chat with all of them.
+func StartChat(documentID string, version int, attachedDocIDs []string, userID string) (ChatSession, error) {
+	doc, err := resolveDocumentVersion(documentID, version, userID, accessChat)
+	if err != nil {
+		return ChatSession{}, err
+	}
+	attached, err := authorizeDocuments(attachedDocIDs, userID, accessChat)
+	if err != nil {
+		return ChatSession{}, fmt.Errorf("attached document: %w", err)
+	}
+	return ChatSession{Document: doc, Attached: attached, userID: userID}, nil
+}
 
+// SaveUserMessage appends the user's message to the conversation.
+func (s ChatSession) SaveUserMessage(userMessage string) (models.ChatMessage, error) {
 	// Always create a valid JSON array, even if it's empty.
-	serializableDocs := make([]map[string]string, len(attachedDocs))
-	for i, doc := range attachedDocs {
+	serializableDocs := make([]map[string]string, len(s.Attached))
+	for i, doc := range s.Attached {
 		serializableDocs[i] = map[string]string{"id": doc.ID, "title": doc.FileName}
 	}
-	attachedDocsJSON, err = json.Marshal(serializableDocs)
+	attachedDocsJSON, err := json.Marshal(serializableDocs)
 	if err != nil {
 		return models.ChatMessage{}, fmt.Errorf("failed to marshal attached documents: %w", err)
 	}
 
 	message := models.ChatMessage{
 		ID:                uuid.NewV4().String(),
-		DocumentID:        documentID,
-		UserID:            userID,
+		DocumentID:        s.Document.VersionGroupID,
+		UserID:            s.userID,
 		MessageType:       "user",
 		MessageContent:    userMessage,
 		Timestamp:         time.Now(),
@@ -45,18 +72,21 @@ func SaveUserMessage(documentID, userID, userMessage string, attachedDocs []mode
 	return message, err
 }
 
-func GetRelevantContext(documentID, userMessage string, attachedDocIDs []string, userID string) (string, error) {
+// relatedChunksLimit is how many chunks are retrieved from other documents in the chat's
+// retrieval scope.
+const relatedChunksLimit = 5
+
+// RelevantContext builds the LLM context from the attached documents, the chunks of the
+// session's document closest to the message and, when scope is not empty, the closest
+// chunks of the user's other documents matching it.
+func (s ChatSession) RelevantContext(userMessage string, scope DocumentFilter) (string, error) {
+	documentID := s.Document.ID
 	var contextBuilder strings.Builder
 
 	// 1. Fetch content from attached documents
-	if len(attachedDocIDs) > 0 {
-		docs, err := GetDocumentsByIDs(attachedDocIDs, userID)
-		if err != nil {
-			return "", fmt.Errorf("failed to get attached documents: %w", err)
-		}
-
-		for _, doc := range docs {
-			content, err := GetDocumentContent(doc.ID)
+	if len(s.Attached) > 0 {
+		for _, doc := range s.Attached {
+			content, err := getDocumentContent(doc.ID)
 			if err != nil {
 				// Log the error but continue, so one failed doc doesn't stop the whole process
 				log.Printf("Warning: failed to get content for attached document %s: %v", doc.ID, err)
@@ -67,19 +97,27 @@ func GetRelevantContext(documentID, userMessage string, attachedDocIDs []string,
 	}
 
 	// 2. Fetch relevant chunks from the main document
-	userMessageEmbedding, err := llm.GetEmbedding(userMessage)
+	model := activeEmbeddingModel()
+	userMessageEmbedding, err := getEmbedding(model, userMessage)
 	if err != nil {
 		return "", err
 	}
 
+	// A chunk's embedding for the active model is either its own (when it was ingested with
+	// that model) or one written by a re-embedding backfill. Chunks with neither sort last.
 	query := `
-		SELECT content, chunk_index
-		FROM document_chunks
-		WHERE document_id = $1
-		ORDER BY embedding <=> $2
+		SELECT dc.content, dc.chunk_index
+		FROM document_chunks dc
+		LEFT JOIN chunk_embeddings ce
+		       ON ce.chunk_id = dc.id AND ce.model = $3 AND ce.dimensions = $4
+		WHERE dc.document_id = $1
+		ORDER BY COALESCE(
+		    CASE WHEN dc.embedding_model = $3 AND dc.embedding_dimensions = $4 THEN dc.embedding END,
+		    ce.embedding
+		) <=> $2
 		LIMIT 3
 	`
-	rows, err := database.DB.Query(query, documentID, pgvector.NewVector(userMessageEmbedding))
+	rows, err := database.DB.Query(query, documentID, pgvector.NewVector(userMessageEmbedding), model.Name, model.Dimensions)
 	if err != nil {
 		return "", err
 	}
@@ -108,18 +146,22 @@ func GetRelevantContext(documentID, userMessage string, attachedDocIDs []string,
 		chunks = append(chunks, c.Content)
 	}
 
-	// 3. Combine the contexts
+	// 3. Fetch relevant chunks from other documents in the retrieval scope
+	if !scope.IsEmpty() {
+		related, err := getRelatedChunks(s.Document, s.userID, scope, userMessageEmbedding, model)
+		if err != nil {
+			return "", fmt.Errorf("failed to search documents in the retrieval scope: %w", err)
+		}
+		for _, doc := range related {
+			contextBuilder.WriteString(fmt.Sprintf("<document>\n<title>%s</title>\n<content>\n%s\n</content>\n</document>\n", doc.FileName, strings.Join(doc.Chunks, "\n\n")))
+		}
+	}
+
+	// 4. Combine the contexts
 	mainDocContext := strings.Join(chunks, "\n\n")
 	if contextBuilder.Len() > 0 {
 		// We have attached documents, so we wrap the main doc context as well
-		mainDocInfo, err := GetDocumentStatus(documentID, userID)
-		if err != nil {
-			log.Printf("Warning: could not get main document info for %s: %v", documentID, err)
-			// Fallback to just using the content
-			contextBuilder.WriteString(fmt.Sprintf("<document>\n<title>Current Document</title>\n<content>\n%s\n</content>\n</document>\n", mainDocContext))
-		} else {
-			contextBuilder.WriteString(fmt.Sprintf("<document>\n<title>%s</title>\n<content>\n%s\n</content>\n</document>\n", mainDocInfo.FileName, mainDocContext))
-		}
+		contextBuilder.WriteString(fmt.Sprintf("<document>\n<title>%s</title>\n<content>\n%s\n</content>\n</document>\n", s.Document.FileName, mainDocContext))
 		return contextBuilder.String(), nil
 	}
 
@@ -127,32 +169,155 @@ func GetRelevantContext(documentID, userMessage string, attachedDocIDs []string,
 	return mainDocContext, nil
 }
 
-func GetChatHistory(documentID string) ([]models.ChatMessage, error) {
-	query := `SELECT id, document_id, user_id, message_type, message_content, timestamp, attached_documents FROM chat_history WHERE document_id = $1 ORDER BY timestamp`
-	rows, err := database.DB.Query(query, documentID)
+type relatedDocument struct {
+	FileName string
+	Chunks   []string
+}
+
+// getRelatedChunks returns the chunks closest to the query embedding from the latest
+// versions of the processed documents the user may chat with that match scope, grouped by
+// document. The versions of the main document are excluded.
+func getRelatedChunks(main models.Document, userID string, scope DocumentFilter, queryEmbedding []float32, model llm.EmbeddingModel) ([]relatedDocument, error) {
+	args := []any{userID, pgvector.NewVector(queryEmbedding), model.Name, main.VersionGroupID, relatedChunksLimit}
+	conditions, args, err := scope.conditions("d", args)
 	if err != nil {
 		return nil, err
 	}
+
+	where := accessibleCondition("d", accessChat) + ` AND d.status = 'processed'` + conditions + `
+		  AND d.version_group_id <> $4
+		  AND NOT EXISTS (
+		      SELECT 1 FROM documents newer
+		      WHERE newer.version_group_id = d.version_group_id AND newer.version > d.version
+		  )`
+	query := nearestChunksQuery(model, "d.id, d.file_name, dc.content", where, 0, "$5")
+
+	var related []relatedDocument
+	positions := make(map[string]int)
+	err = queryNearestChunks(query, args, func(rows *sql.Rows) error {
+		for rows.Next() {
+			var docID, fileName, content, chunkID string
+			var distance float64
+			if err := rows.Scan(&docID, &fileName, &content, &chunkID, &distance); err != nil {
+				return err
+			}
+			i, ok := positions[docID]
+			if !ok {
+				i = len(related)
+				positions[docID] = i
+				related = append(related, relatedDocument{FileName: fileName})
+			}
+			related[i].Chunks = append(related[i].Chunks, content)
+		}
+		return nil
+	})
+	return related, err
+}
+
+// ErrInvalidChatCursor is returned when a chat history cursor names a message that isn't
+// part of the conversation, or when both cursors are given.
+var ErrInvalidChatCursor = errors.New("invalid chat history cursor")
+
+const (
+	defaultChatPageSize = 50
+	maxChatPageSize     = 200
+)
+
+// ChatHistoryOptions selects a page of a conversation. With neither cursor set the page
+// holds the latest messages.
+type ChatHistoryOptions struct {
+	// BeforeID selects the messages preceding this message.
+	BeforeID string
+	// AfterID selects the messages following this message.
+	AfterID string
+	// Limit defaults to defaultChatPageSize and is capped at maxChatPageSize.
+	Limit int
+}
+
+// ChatHistoryPage is a page of a conversation in chronological order. HasMore tells whether
+// there are further messages in the paging direction: older ones, or newer ones when paging
+// with AfterID.
+type ChatHistoryPage struct {
+	Messages []models.ChatMessage `json:"messages"`
+	HasMore  bool                 `json:"has_more"`
+}
+
+// GetChatHistory returns a page of the user's conversation about the document documentID
+// belongs to, which is shared by all its versions; everyone the document is shared with has
+// their own. Messages are ordered by insertion, so messages with equal timestamps keep their
+// order.
+func GetChatHistory(documentID, userID string, opts ChatHistoryOptions) (ChatHistoryPage, error) {
+	if opts.BeforeID != "" && opts.AfterID != "" {
+		return ChatHistoryPage{}, fmt.Errorf("%w: before and after cannot be combined", ErrInvalidChatCursor)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultChatPageSize
+	}
+	opts.Limit = min(opts.Limit, maxChatPageSize)
+
+	doc, err := authorizeDocument(documentID, userID, accessView)
+	if err != nil {
+		return ChatHistoryPage{}, err
+	}
+	conversationID := doc.VersionGroupID
+
+	args := []any{conversationID, opts.Limit + 1, userID}
+	condition, order := "", "DESC"
+	if cursorID := opts.BeforeID + opts.AfterID; cursorID != "" {
+		var cursorSeq int64
+		err := database.DB.QueryRow(`SELECT seq FROM chat_history WHERE id = $1 AND document_id = $2 AND user_id = $3`, cursorID, conversationID, userID).Scan(&cursorSeq)
+		if err == sql.ErrNoRows {
+			return ChatHistoryPage{}, fmt.Errorf("%w: message %s is not part of this conversation", ErrInvalidChatCursor, cursorID)
+		} else if err != nil {
+			return ChatHistoryPage{}, err
+		}
+		args = append(args, cursorSeq)
+		if opts.AfterID != "" {
+			condition, order = " AND seq > $4", "ASC"
+		} else {
+			condition = " AND seq < $4"
+		}
+	}
+
+	// One extra row tells whether there are more messages.
+	query := `SELECT id, document_id, user_id, message_type, message_content, timestamp, attached_documents FROM chat_history
+		WHERE document_id = $1 AND user_id = $3` + condition + ` ORDER BY seq ` + order + ` LIMIT $2`
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return ChatHistoryPage{}, err
+	}
 	defer rows.Close()
 
-	history := make([]models.ChatMessage, 0)
+	page := ChatHistoryPage{Messages: make([]models.ChatMessage, 0, opts.Limit)}
 	for rows.Next() {
+		if len(page.Messages) == opts.Limit {
+			page.HasMore = true
+			break
+		}
 		var msg models.ChatMessage
 		var attachedDocsBytes []byte
 		if err := rows.Scan(&msg.ID, &msg.DocumentID, &msg.UserID, &msg.MessageType, &msg.MessageContent, &msg.Timestamp, &attachedDocsBytes); err != nil {
-			return nil, err
+			return ChatHistoryPage{}, err
 		}
 		// The frontend expects a JSON string, so we just assign it.
 		// The model has `omitempty`, so it will be null if empty.
 		msg.AttachedDocuments = string(attachedDocsBytes)
-		history = append(history, msg)
+		page.Messages = append(page.Messages, msg)
 	}
-	return history, nil
+	if err := rows.Err(); err != nil {
+		return ChatHistoryPage{}, err
+	}
+
+	if order == "DESC" {
+		slices.Reverse(page.Messages)
+	}
+	return page, nil
 }
 
-func GetChatHistoryForLLM(documentID string) ([]*genai.Content, error) {
-	query := `SELECT message_type, message_content FROM chat_history WHERE document_id = $1 ORDER BY timestamp`
-	rows, err := database.DB.Query(query, documentID)
+// HistoryForLLM returns the user's whole conversation as LLM content.
+func (s ChatSession) HistoryForLLM() ([]*genai.Content, error) {
+	query := `SELECT message_type, message_content FROM chat_history WHERE document_id = $1 AND user_id = $2 ORDER BY seq`
+	rows, err := database.DB.Query(query, s.Document.VersionGroupID, s.userID)
 	if err != nil {
 		return nil, err
 	}
@@ -185,11 +350,12 @@ func StreamChatResponse(userMessage, contextText string, history []*genai.Conten
 	return fullResponse, nil
 }
 
-func SaveAIMessage(documentID, userID, aiResponse string) (models.ChatMessage, error) {
+// SaveAIMessage appends the LLM's answer to the conversation.
+func (s ChatSession) SaveAIMessage(aiResponse string) (models.ChatMessage, error) {
 	message := models.ChatMessage{
 		ID:             uuid.NewV4().String(),
-		DocumentID:     documentID,
-		UserID:         userID,
+		DocumentID:     s.Document.VersionGroupID,
+		UserID:         s.userID,
 		MessageType:    "ai",
 		MessageContent: aiResponse,
 		Timestamp:      time.Now(),
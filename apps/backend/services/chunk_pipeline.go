@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/database"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pgvector/pgvector-go"
+	uuid "github.com/satori/go.uuid"
+)
+
+type pendingChunk struct {
+	index    int
+	content  string
+	metadata map[string]string
+}
+
+// chunkPipeline embeds and stores a document's chunks in batches, using a bounded pool
+// of workers so several batches are in flight at once. Chunks are added as the extractor
+// produces them; Wait flushes the last batch and returns the first error. The first error
+// cancels the batches in flight and skips the queued ones, as the document has failed.
+type chunkPipeline struct {
+	docID     string
+	ctx       context.Context
+	cancel    context.CancelFunc
+	batchSize int
+	pending   []pendingChunk
+	batches   chan []pendingChunk
+	failed    chan struct{}
+	wg        sync.WaitGroup
+	errOnce   sync.Once
+	err       error
+}
+
+func newChunkPipeline(docID string) *chunkPipeline {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &chunkPipeline{
+		docID:     docID,
+		ctx:       ctx,
+		cancel:    cancel,
+		batchSize: int(config.AppConfig.EmbeddingBatchSize),
+		batches:   make(chan []pendingChunk),
+		failed:    make(chan struct{}),
+	}
+
+	for i := 0; i < int(config.AppConfig.EmbeddingConcurrency); i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			for batch := range p.batches {
+				if p.ctx.Err() != nil {
+					continue
+				}
+				if err := saveChunkBatch(p.ctx, p.docID, batch); err != nil {
+					p.fail(err)
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// Add queues a chunk, blocking while all workers are busy. It returns the pipeline's
+// error once a batch has failed so the extractor can stop early.
+func (p *chunkPipeline) Add(content string, chunkIndex int, metadata map[string]string) error {
+	p.pending = append(p.pending, pendingChunk{index: chunkIndex, content: content, metadata: metadata})
+	if len(p.pending) < p.batchSize {
+		return nil
+	}
+	return p.flush()
+}
+
+// Wait sends the remaining chunks, waits for all batches to be stored and returns the
+// first error, if any.
+func (p *chunkPipeline) Wait() error {
+	flushErr := p.flush()
+	close(p.batches)
+	p.wg.Wait()
+	p.cancel()
+	if p.err != nil {
+		return p.err
+	}
+	return flushErr
+}
+
+func (p *chunkPipeline) flush() error {
+	if len(p.pending) == 0 {
+		return nil
+	}
+	batch := p.pending
+	p.pending = nil
+
+	select {
+	case p.batches <- batch:
+		return nil
+	case <-p.failed:
+		return p.err
+	}
+}
+
+func (p *chunkPipeline) fail(err error) {
+	p.errOnce.Do(func() {
+		p.err = err
+		close(p.failed)
+		p.cancel()
+	})
+}
+
+// saveChunkBatch embeds a batch of chunks with one provider call and stores them with
+// a single multi-row insert. During a model migration the batch is also embedded with the
+// next model; failing that only logs a warning, as the backfill will catch up.
+func saveChunkBatch(ctx context.Context, docID string, batch []pendingChunk) error {
+	first, last := batch[0].index, batch[len(batch)-1].index
+	log.Printf("Processing chunks %d-%d for document %s", first, last, docID)
+
+	texts := make([]string, len(batch))
+	for i, chunk := range batch {
+		texts[i] = chunk.content
+	}
+	model := activeEmbeddingModel()
+	embeddings, err := getEmbeddings(ctx, model, texts)
+	if err != nil {
+		log.Printf("ERROR: Failed to generate embeddings for chunks %d-%d for document %s: %v", first, last, docID, err)
+		return fmt.Errorf("failed to embed chunks %d-%d: %w", first, last, err)
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO document_chunks (id, document_id, chunk_index, content, embedding, embedding_model, embedding_dimensions, metadata, created_at) VALUES `)
+	args := []any{model.Name, model.Dimensions, docID, time.Now()}
+	chunkIDs := make([]string, len(batch))
+	for i, chunk := range batch {
+		// A nil []byte would be sent as an empty string, which isn't valid JSONB.
+		var metadata any
+		if len(chunk.metadata) > 0 {
+			metadataJSON, err := json.Marshal(chunk.metadata)
+			if err != nil {
+				return fmt.Errorf("failed to marshal chunk metadata: %w", err)
+			}
+			metadata = metadataJSON
+		}
+
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "($%d, $3, $%d, $%d, $%d, $1, $2, $%d, $4)", n+1, n+2, n+3, n+4, n+5)
+		chunkIDs[i] = uuid.NewV4().String()
+		args = append(args, chunkIDs[i], chunk.index, chunk.content, pgvector.NewVector(embeddings[i]), metadata)
+	}
+
+	if _, err := database.DB.ExecContext(ctx, query.String(), args...); err != nil {
+		log.Printf("ERROR: Failed to save chunks %d-%d for document %s: %v", first, last, docID, err)
+		return fmt.Errorf("failed to save chunks %d-%d: %w", first, last, err)
+	}
+
+	if next, ok := nextEmbeddingModel(); ok {
+		if err := embedChunksWith(ctx, next, chunkIDs, texts); err != nil {
+			log.Printf("Warning: failed to embed chunks %d-%d for document %s with %s: %v", first, last, docID, next, err)
+		}
+	}
+	log.Printf("Successfully saved chunks %d-%d for document %s", first, last, docID)
+	return nil
+}
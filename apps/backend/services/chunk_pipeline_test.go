@@ -0,0 +1,46 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strategic-insight-analyst/backend/database"
+	"testing"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+func TestSaveChunkBatchMetadata(t *testing.T) {
+	openTestDB(t)
+	doc := newTestDocument(t, newTestUser(t), "")
+	batch := []pendingChunk{
+		{index: 1, content: "A chunk without metadata."},
+		{index: 2, content: "A chunk with metadata.", metadata: map[string]string{"page": "3"}},
+	}
+	model := activeEmbeddingModel()
+	for _, chunk := range batch {
+		mustExec(t, `INSERT INTO embedding_cache (model, dimensions, text_hash, embedding) VALUES ($1, $2, $3, $4) ON CONFLICT DO NOTHING`,
+			model.Name, model.Dimensions, hashText(chunk.content), pgvector.NewVector([]float32{0, 1, 0}))
+	}
+
+	if err := saveChunkBatch(context.Background(), doc.ID, batch); err != nil {
+		t.Fatalf("saveChunkBatch() error = %v", err)
+	}
+
+	tests := []struct {
+		index int
+		want  sql.NullString
+	}{
+		{1, sql.NullString{}},
+		{2, sql.NullString{String: "3", Valid: true}},
+	}
+	for _, tt := range tests {
+		var page sql.NullString
+		err := database.DB.QueryRow(`SELECT metadata->>'page' FROM document_chunks WHERE document_id = $1 AND chunk_index = $2`, doc.ID, tt.index).Scan(&page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page != tt.want {
+			t.Errorf("chunk %d: page = %+v, want %+v", tt.index, page, tt.want)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/internal/llm"
+)
+
+// nearestChunksQuery returns a query selecting columns and the cosine distance ("distance")
+// to the query embedding $2 of the chunks dc of the documents d matching where, ordered by
+// distance and chunk ID. $3 is the model's name.
+//
+// A chunk's embedding for the model is either its own, when it was ingested with the model,
+// or one written by a re-embedding backfill. Each kind is ranked in its own branch ordering
+// by the expression the model's HNSW index covers, which is why the dimension is inlined.
+// With afterArg set, only chunks after the distance $afterArg and chunk ID $afterArg+1 are
+// selected; limit bounds each branch as well as the result.
+func nearestChunksQuery(model llm.EmbeddingModel, columns, where string, afterArg int, limit string) string {
+	branch := func(embedding, from, modelCondition string) string {
+		distance := fmt.Sprintf("%s::vector(%d) <=> $2", embedding, model.Dimensions)
+		after := ""
+		if afterArg > 0 {
+			after = fmt.Sprintf(" AND (%s, dc.id) > ($%d, $%d)", distance, afterArg, afterArg+1)
+		}
+		return fmt.Sprintf(`(
+			SELECT %s, dc.id AS nearest_chunk_id, %s AS distance
+			FROM %s
+			JOIN documents d ON d.id = dc.document_id
+			WHERE %s AND %s%s
+			ORDER BY distance, dc.id
+			LIMIT %s
+		)`, columns, distance, from, modelCondition, where, after, limit)
+	}
+	own := fmt.Sprintf("dc.embedding_model = $3 AND dc.embedding_dimensions = %d", model.Dimensions)
+	return `
+		SELECT * FROM (` +
+		branch("dc.embedding", "document_chunks dc", own) + `
+		UNION ALL ` +
+		branch("ce.embedding", "chunk_embeddings ce JOIN document_chunks dc ON dc.id = ce.chunk_id",
+			fmt.Sprintf("ce.model = $3 AND ce.dimensions = %d AND NOT (%s)", model.Dimensions, own)) + `
+		) nearest
+		ORDER BY distance, nearest_chunk_id
+		LIMIT ` + limit
+}
+
+// queryNearestChunks runs a nearestChunksQuery and passes the rows to scan. HNSW index
+// scans stop after hnsw.ef_search candidates, which filters on the documents can leave
+// short of the limit; iterative scans (pgvector 0.8) keep scanning until enough rows pass.
+// Older pgvector versions run the query without them.
+func queryNearestChunks(query string, args []any, scan func(*sql.Rows) error) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("SET LOCAL hnsw.iterative_scan = strict_order"); err != nil {
+		log.Printf("Warning: HNSW iterative scans are unavailable, search results may be incomplete: %v", err)
+		tx.Rollback()
+		if tx, err = database.DB.Begin(); err != nil {
+			return err
+		}
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	if err := scan(rows); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
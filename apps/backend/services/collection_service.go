@@ -0,0 +1,256 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+
+	"github.com/lib/pq"
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrInvalidCollection is returned for collection changes that fail validation.
+	ErrInvalidCollection = errors.New("invalid collection")
+	// ErrDuplicateName is returned when a sibling collection or another tag of the user
+	// already has the name, ignoring case.
+	ErrDuplicateName = errors.New("the name is already in use")
+)
+
+const maxCollectionNameLength = 255
+
+const collectionColumns = `id, user_id, name, parent_id, created_at`
+
+// visibleCollectionCondition holds for collections of the user passed as $1 and for those
+// shared with them.
+var visibleCollectionCondition = `(user_id = $1 OR id IN (` + sharedCollectionsQuery(sharePermissionsGranting(accessView)) + `))`
+
+func scanCollection(row rowScanner) (models.Collection, error) {
+	var collection models.Collection
+	var parentID sql.NullString
+	if err := row.Scan(&collection.ID, &collection.UserID, &collection.Name, &parentID, &collection.CreatedAt); err != nil {
+		return models.Collection{}, err
+	}
+	collection.ParentID = parentID.String
+	return collection, nil
+}
+
+// CreateCollection creates a collection for the user, nested in parentID unless it is empty.
+// It returns sql.ErrNoRows if the user has no such parent collection.
+func CreateCollection(userID, name, parentID string) (models.Collection, error) {
+	name, err := validCollectionName(name)
+	if err != nil {
+		return models.Collection{}, err
+	}
+	if parentID != "" {
+		if _, err := getOwnedCollection(parentID, userID); err != nil {
+			return models.Collection{}, err
+		}
+	}
+
+	query := `INSERT INTO collections (id, user_id, name, parent_id) VALUES ($1, $2, $3, $4) RETURNING ` + collectionColumns
+	collection, err := scanCollection(database.DB.QueryRow(query, uuid.NewV4().String(), userID, name, nullString(parentID)))
+	if isUniqueViolation(err) {
+		return models.Collection{}, ErrDuplicateName
+	}
+	return collection, err
+}
+
+// GetUserCollections lists all the user's collections and the collections shared with them.
+// Clients build the tree from ParentID; shared collections whose parent isn't listed are
+// top-level for the user.
+func GetUserCollections(userID string) ([]models.Collection, error) {
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE ` + visibleCollectionCondition + ` ORDER BY lower(name)`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := make([]models.Collection, 0)
+	for rows.Next() {
+		collection, err := scanCollection(rows)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, collection)
+	}
+	return collections, rows.Err()
+}
+
+// GetCollection returns a collection of the user's or shared with them, or sql.ErrNoRows if
+// there is no such collection.
+func GetCollection(collectionID, userID string) (models.Collection, error) {
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE id = $2 AND ` + visibleCollectionCondition
+	return scanCollection(database.DB.QueryRow(query, userID, collectionID))
+}
+
+// getOwnedCollection returns the user's collection, or sql.ErrNoRows if the user doesn't own
+// such a collection. Only owners change collections and their contents.
+func getOwnedCollection(collectionID, userID string) (models.Collection, error) {
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE id = $1 AND user_id = $2`
+	return scanCollection(database.DB.QueryRow(query, collectionID, userID))
+}
+
+// CollectionUpdate renames or moves a collection. Nil fields are left unchanged; an empty
+// ParentID moves the collection to the top level.
+type CollectionUpdate struct {
+	Name     *string
+	ParentID *string
+}
+
+// UpdateCollection applies update to the user's collection and returns the updated
+// collection. It returns sql.ErrNoRows if the user has no such collection or new parent.
+func UpdateCollection(collectionID, userID string, update CollectionUpdate) (models.Collection, error) {
+	if update.Name != nil {
+		name, err := validCollectionName(*update.Name)
+		if err != nil {
+			return models.Collection{}, err
+		}
+		update.Name = &name
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return models.Collection{}, err
+	}
+	defer tx.Rollback()
+
+	// Moves of the user's collections are serialized: two concurrent moves could each pass
+	// the cycle check against the tree the other is changing and together create a cycle.
+	if update.ParentID != nil {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1))`, userID); err != nil {
+			return models.Collection{}, err
+		}
+	}
+
+	query := `SELECT ` + collectionColumns + ` FROM collections WHERE id = $1 AND user_id = $2 FOR UPDATE`
+	collection, err := scanCollection(tx.QueryRow(query, collectionID, userID))
+	if err != nil {
+		return models.Collection{}, err
+	}
+
+	if update.Name != nil {
+		collection.Name = *update.Name
+	}
+	if update.ParentID != nil && *update.ParentID != collection.ParentID {
+		if *update.ParentID != "" {
+			if err := checkCollectionMove(tx, collectionID, *update.ParentID, userID); err != nil {
+				return models.Collection{}, err
+			}
+		}
+		collection.ParentID = *update.ParentID
+	}
+
+	updateQuery := `UPDATE collections SET name = $1, parent_id = $2 WHERE id = $3`
+	if _, err := tx.Exec(updateQuery, collection.Name, nullString(collection.ParentID), collection.ID); err != nil {
+		if isUniqueViolation(err) {
+			return models.Collection{}, ErrDuplicateName
+		}
+		return models.Collection{}, fmt.Errorf("failed to update collection: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Collection{}, err
+	}
+	return collection, nil
+}
+
+// checkCollectionMove checks that parentID is one of the user's collections and not the
+// collection itself or one of its subcollections.
+func checkCollectionMove(tx *sql.Tx, collectionID, parentID, userID string) error {
+	// Walk up from the new parent; reaching the collection would create a cycle.
+	query := `
+		WITH RECURSIVE ancestors AS (
+			SELECT id, parent_id FROM collections WHERE id = $1 AND user_id = $2
+			UNION
+			SELECT c.id, c.parent_id FROM collections c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE id = $3) FROM ancestors
+	`
+	var found, cycles int
+	if err := tx.QueryRow(query, parentID, userID, collectionID).Scan(&found, &cycles); err != nil {
+		return err
+	}
+	if found == 0 {
+		return sql.ErrNoRows
+	}
+	if cycles > 0 {
+		return fmt.Errorf("%w: a collection cannot be moved into itself or one of its subcollections", ErrInvalidCollection)
+	}
+	return nil
+}
+
+// DeleteCollection deletes the user's collection and its subcollections. The documents in
+// them are kept. It returns sql.ErrNoRows if the user has no such collection.
+func DeleteCollection(collectionID, userID string) error {
+	result, err := database.DB.Exec(`DELETE FROM collections WHERE id = $1 AND user_id = $2`, collectionID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
+
+// AddDocumentToCollection adds all versions of the user's document to the collection. Adding
+// a document that is already in the collection is not an error. It returns sql.ErrNoRows if
+// the user has no such collection.
+func AddDocumentToCollection(collectionID, documentID, userID string) error {
+	if _, err := getOwnedCollection(collectionID, userID); err != nil {
+		return err
+	}
+	doc, err := authorizeDocument(documentID, userID, accessOwner)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO document_collections (collection_id, document_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err = database.DB.Exec(query, collectionID, doc.VersionGroupID)
+	return err
+}
+
+// RemoveDocumentFromCollection removes the user's document from the collection. It returns
+// sql.ErrNoRows if the document is not in the collection.
+func RemoveDocumentFromCollection(collectionID, documentID, userID string) error {
+	doc, err := authorizeDocument(documentID, userID, accessOwner)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM document_collections
+		WHERE collection_id = (SELECT id FROM collections WHERE id = $1 AND user_id = $3)
+		  AND document_id = $2
+	`
+	result, err := database.DB.Exec(query, collectionID, doc.VersionGroupID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
+
+func validCollectionName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxCollectionNameLength {
+		return "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidCollection, maxCollectionNameLength)
+	}
+	return name, nil
+}
+
+// requireAffectedRow returns sql.ErrNoRows if a statement changed nothing.
+func requireAffectedRow(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
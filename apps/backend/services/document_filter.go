@@ -0,0 +1,75 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// DocumentFilter narrows the documents listed or searched for a user. WorkspaceID selects
+// the space; of the other fields, empty ones don't filter and set ones must all match.
+type DocumentFilter struct {
+	// WorkspaceID matches the documents of the workspace. When empty, the personal space is
+	// matched: the user's documents outside workspaces and those shared with them.
+	WorkspaceID string
+	// Metadata matches documents having all these metadata fields with equal values.
+	Metadata map[string]any
+	// CollectionID matches documents in the collection or any of its subcollections. The
+	// collection must be the user's or shared with them.
+	CollectionID string
+	// TagIDs matches documents having all these tags.
+	TagIDs []string
+}
+
+// IsEmpty reports whether the filter matches all documents of its space.
+func (f DocumentFilter) IsEmpty() bool {
+	return len(f.Metadata) == 0 && f.CollectionID == "" && len(f.TagIDs) == 0
+}
+
+// conditions returns the filter as SQL conditions, each prefixed with AND, on the documents
+// table aliased as alias. The query must pass the user's ID as $1; the filter's own
+// parameters are appended to args.
+func (f DocumentFilter) conditions(alias string, args []any) (string, []any, error) {
+	var b strings.Builder
+	if f.WorkspaceID != "" {
+		args = append(args, f.WorkspaceID)
+		fmt.Fprintf(&b, " AND %s.workspace_id = $%d", alias, len(args))
+	} else {
+		fmt.Fprintf(&b, " AND %s.workspace_id IS NULL", alias)
+	}
+	if len(f.Metadata) > 0 {
+		metadataJSON, err := json.Marshal(f.Metadata)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, metadataJSON)
+		fmt.Fprintf(&b, " AND %s.metadata @> $%d", alias, len(args))
+	}
+	if f.CollectionID != "" {
+		args = append(args, f.CollectionID)
+		fmt.Fprintf(&b, ` AND %s.version_group_id IN (
+			SELECT dcol.document_id FROM document_collections dcol
+			WHERE dcol.collection_id IN (
+				WITH RECURSIVE subtree AS (
+					SELECT id FROM collections WHERE id = $%d AND (user_id = $1 OR id IN (%s))
+					UNION
+					SELECT c.id FROM collections c JOIN subtree ON c.parent_id = subtree.id
+				)
+				SELECT id FROM subtree
+			)
+		)`, alias, len(args), sharedCollectionsQuery(sharePermissionsGranting(accessView)))
+	}
+	if len(f.TagIDs) > 0 {
+		args = append(args, pq.Array(f.TagIDs))
+		fmt.Fprintf(&b, ` AND NOT EXISTS (
+			SELECT 1 FROM unnest($%d::text[]) AS wanted(tag_id)
+			WHERE NOT EXISTS (
+				SELECT 1 FROM document_tags dt
+				WHERE dt.document_id = %s.version_group_id AND dt.tag_id = wanted.tag_id
+			)
+		)`, len(args), alias)
+	}
+	return b.String(), args, nil
+}
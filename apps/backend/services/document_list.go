@@ -0,0 +1,224 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ErrInvalidListOptions is returned for document list options that fail validation,
+// including cursors that are malformed or were issued for a different sort order.
+var ErrInvalidListOptions = errors.New("invalid document list options")
+
+const (
+	defaultDocumentPageSize = 50
+	maxDocumentPageSize     = 200
+)
+
+// Document list sort orders.
+const (
+	SortByDate   = "date"
+	SortByName   = "name"
+	SortByStatus = "status"
+	SortBySize   = "size"
+)
+
+// documentSortKeys maps each sort order to its SQL expression and the type its cursor
+// value is cast back to.
+var documentSortKeys = map[string]struct{ expr, sqlType string }{
+	SortByDate:   {"documents.created_at", "timestamptz"},
+	SortByName:   {"lower(documents.file_name)", "text"},
+	SortByStatus: {"documents.status", "text"},
+	SortBySize:   {"COALESCE(documents.size_bytes, 0)", "bigint"},
+}
+
+var documentStatuses = []string{"processing", "processed", "failed"}
+
+// DocumentListOptions pages, sorts and filters the document list. Zero values don't filter.
+type DocumentListOptions struct {
+	DocumentFilter
+	Statuses     []string
+	ContentTypes []string
+	// CreatedAfter is inclusive and CreatedBefore exclusive.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// NameContains matches file names containing it, ignoring case.
+	NameContains string
+	// SortBy is one of the SortBy constants and defaults to SortByDate.
+	SortBy    string
+	Ascending bool
+	// Limit defaults to defaultDocumentPageSize and is capped at maxDocumentPageSize.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// DocumentPage is one page of the document list. NextCursor is empty on the last page;
+// TotalCount counts the matching documents across all pages.
+type DocumentPage struct {
+	Documents  []models.Document `json:"documents"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+	TotalCount int               `json:"total_count"`
+}
+
+// documentCursor points after the last document of a page. Ties in the sort key are
+// broken by document ID.
+type documentCursor struct {
+	SortBy    string `json:"s"`
+	Ascending bool   `json:"a"`
+	Value     string `json:"v"`
+	ID        string `json:"id"`
+}
+
+// GetUserDocuments lists a page of the latest versions of the user's documents and of the
+// documents shared with them.
+func GetUserDocuments(userID string, opts DocumentListOptions) (DocumentPage, error) {
+	if opts.SortBy == "" {
+		opts.SortBy = SortByDate
+	}
+	sortKey, ok := documentSortKeys[opts.SortBy]
+	if !ok {
+		return DocumentPage{}, fmt.Errorf("%w: sort must be one of %s, %s, %s or %s", ErrInvalidListOptions, SortByDate, SortByName, SortByStatus, SortBySize)
+	}
+	for _, status := range opts.Statuses {
+		if !slices.Contains(documentStatuses, status) {
+			return DocumentPage{}, fmt.Errorf("%w: status must be one of %s", ErrInvalidListOptions, strings.Join(documentStatuses, ", "))
+		}
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultDocumentPageSize
+	}
+	opts.Limit = min(opts.Limit, maxDocumentPageSize)
+
+	conditions, args, err := opts.conditions("documents", []any{userID})
+	if err != nil {
+		return DocumentPage{}, err
+	}
+	where := `
+		WHERE ` + accessibleCondition("documents", accessView) + conditions + `
+		  AND NOT EXISTS (
+		      SELECT 1 FROM documents newer
+		      WHERE newer.version_group_id = documents.version_group_id AND newer.version > documents.version
+		  )`
+
+	var page DocumentPage
+	if err := database.DB.QueryRow(`SELECT COUNT(*) FROM documents`+where, args...).Scan(&page.TotalCount); err != nil {
+		return DocumentPage{}, err
+	}
+
+	order, comparison := "DESC", "<"
+	if opts.Ascending {
+		order, comparison = "ASC", ">"
+	}
+	if opts.Cursor != "" {
+		cursor, err := decodeDocumentCursor(opts.Cursor)
+		if err != nil || cursor.SortBy != opts.SortBy || cursor.Ascending != opts.Ascending {
+			return DocumentPage{}, fmt.Errorf("%w: the cursor doesn't belong to this sort order", ErrInvalidListOptions)
+		}
+		args = append(args, cursor.Value, cursor.ID)
+		where += fmt.Sprintf(" AND (%s, documents.id) %s ($%d::%s, $%d)", sortKey.expr, comparison, len(args)-1, sortKey.sqlType, len(args))
+	}
+
+	// One extra row tells whether there is a next page.
+	args = append(args, opts.Limit+1)
+	query := `
+		SELECT ` + documentColumns + `, (` + sortKey.expr + `)::text FROM documents` + where + `
+		ORDER BY ` + sortKey.expr + ` ` + order + `, documents.id ` + order + `
+		LIMIT $` + fmt.Sprint(len(args))
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return DocumentPage{}, err
+	}
+	defer rows.Close()
+
+	page.Documents = make([]models.Document, 0, opts.Limit)
+	var lastSortValue string
+	for rows.Next() {
+		var sortValue string
+		doc, err := scanDocument(sortValueScanner{rows, &sortValue})
+		if err != nil {
+			return DocumentPage{}, err
+		}
+		if len(page.Documents) == opts.Limit {
+			last := page.Documents[len(page.Documents)-1]
+			page.NextCursor = encodeDocumentCursor(documentCursor{opts.SortBy, opts.Ascending, lastSortValue, last.ID})
+			break
+		}
+		page.Documents = append(page.Documents, doc)
+		lastSortValue = sortValue
+	}
+	if err := rows.Err(); err != nil {
+		return DocumentPage{}, err
+	}
+	return page, nil
+}
+
+// conditions extends DocumentFilter.conditions with the list-only filters.
+func (opts DocumentListOptions) conditions(alias string, args []any) (string, []any, error) {
+	conditions, args, err := opts.DocumentFilter.conditions(alias, args)
+	if err != nil {
+		return "", nil, err
+	}
+	var b strings.Builder
+	b.WriteString(conditions)
+	if len(opts.Statuses) > 0 {
+		args = append(args, pq.Array(opts.Statuses))
+		fmt.Fprintf(&b, " AND %s.status = ANY($%d)", alias, len(args))
+	}
+	if len(opts.ContentTypes) > 0 {
+		args = append(args, pq.Array(opts.ContentTypes))
+		fmt.Fprintf(&b, " AND %s.content_type = ANY($%d)", alias, len(args))
+	}
+	if !opts.CreatedAfter.IsZero() {
+		args = append(args, opts.CreatedAfter)
+		fmt.Fprintf(&b, " AND %s.created_at >= $%d", alias, len(args))
+	}
+	if !opts.CreatedBefore.IsZero() {
+		args = append(args, opts.CreatedBefore)
+		fmt.Fprintf(&b, " AND %s.created_at < $%d", alias, len(args))
+	}
+	if opts.NameContains != "" {
+		args = append(args, "%"+escapeLike(opts.NameContains)+"%")
+		fmt.Fprintf(&b, " AND %s.file_name ILIKE $%d", alias, len(args))
+	}
+	return b.String(), args, nil
+}
+
+// sortValueScanner scans the sort key selected after the document columns.
+type sortValueScanner struct {
+	rows      *sql.Rows
+	sortValue *string
+}
+
+func (s sortValueScanner) Scan(dest ...any) error {
+	return s.rows.Scan(append(dest, s.sortValue)...)
+}
+
+func encodeDocumentCursor(cursor documentCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeDocumentCursor(raw string) (documentCursor, error) {
+	var cursor documentCursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, err
+	}
+	err = json.Unmarshal(data, &cursor)
+	return cursor, err
+}
+
+// escapeLike escapes the LIKE wildcards in s so it matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -1,153 +1,427 @@
 package services
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
-	"mime/multipart"
 	"strategic-insight-analyst/backend/config"
 	"strategic-insight-analyst/backend/database"
-	"strategic-insight-analyst/backend/internal/llm"
+	"strategic-insight-analyst/backend/internal/fetcher"
 	"strategic-insight-analyst/backend/internal/processor"
 	"strategic-insight-analyst/backend/internal/storage"
 	"strategic-insight-analyst/backend/models"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/lib/pq"
-	"github.com/pgvector/pgvector-go"
 	uuid "github.com/satori/go.uuid"
 )
 
-func ProcessAndSaveDocument(file multipart.File, handler *multipart.FileHeader, userID string) (models.Document, error) {
-	gcsPath, err := storage.UploadFile(file, handler)
+// ProcessAndSaveDocument records an upload that has already been streamed to GCS in the
+// workspace, or the user's personal space when workspaceID is empty, and processes it in
+// the background as fileType, which the caller has detected from the file's content.
+// Processing reads the stored object, not the request body.
+func ProcessAndSaveDocument(object storage.UploadedObject, fileName string, fileType string, userID, workspaceID string) (models.Document, error) {
+	if err := authorizeUpload(workspaceID, userID); err != nil {
+		return models.Document{}, err
+	}
+
+	doc := newDocument(userID, fileName, fileType, object)
+	doc.WorkspaceID = workspaceID
+	doc, err := createDocumentRecord(doc)
 	if err != nil {
-		return models.Document{}, fmt.Errorf("failed to upload file to GCS: %w", err)
+		return models.Document{}, err
 	}
 
-	doc := models.Document{
-		ID:        uuid.NewV4().String(),
-		UserID:    userID,
-		FileName:  handler.Filename,
-		GCSPath:   gcsPath,
-		Status:    "processing",
-		CreatedAt: time.Now(),
+	go processStoredDocument(doc, fileType)
+
+	return doc, nil
+}
+
+// newDocument builds the record for a file stored in GCS.
+func newDocument(userID, fileName, fileType string, object storage.UploadedObject) models.Document {
+	return models.Document{
+		UserID:      userID,
+		FileName:    fileName,
+		GCSPath:     object.ObjectName,
+		ContentType: fileType,
+		SizeBytes:   object.Size,
+		ContentHash: object.SHA256,
 	}
-	query := `INSERT INTO documents (id, user_id, file_name, gcs_path, status, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err = database.DB.Exec(query, doc.ID, doc.UserID, doc.FileName, doc.GCSPath, doc.Status, doc.CreatedAt)
+}
+
+// ImportDocumentFromURL fetches a remote file, stores it in GCS and processes it like an upload.
+func ImportDocumentFromURL(rawURL string, userID, workspaceID string) (models.Document, error) {
+	if err := authorizeUpload(workspaceID, userID); err != nil {
+		return models.Document{}, err
+	}
+
+	result, err := fetcher.Fetch(rawURL, config.AppConfig.URLImportMaxBytes, config.AppConfig.URLImportTimeout)
 	if err != nil {
-		return models.Document{}, fmt.Errorf("failed to create document record: %w", err)
+		return models.Document{}, err
 	}
 
-	go func() {
-		file.Seek(0, 0)
-		fileType := handler.Header.Get("Content-Type")
-		var processingErr error
+	// Servers often send a generic or wrong Content-Type, so the content decides.
+	fileType, err := processor.DetectFileType(sniffHead(result.Data), result.ContentType, result.FileName)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if !processor.IsSupportedFileType(fileType) {
+		return models.Document{}, fmt.Errorf("%w: %s is not supported for URL imports", processor.ErrUnsupportedFileType, fileType)
+	}
 
-		if fileType == "application/pdf" {
-			log.Printf("Starting PDF chunk processing for document %s", doc.ID)
-			processingErr = processor.ProcessPDFChunks(file, 10000, 200, func(chunk string, chunkIndex int) error {
-				return processChunk(chunk, doc.ID, chunkIndex)
-			})
-		} else {
-			textContent, err := processor.ExtractText(file, fileType)
-			if err != nil {
-				processingErr = fmt.Errorf("failed to extract text: %w", err)
-			} else {
-				chunks := processor.ChunkText(textContent, 10000, 500)
-
-				for i, chunk := range chunks {
-					if err := processChunk(chunk, doc.ID, i); err != nil {
-						processingErr = fmt.Errorf("failed to process chunk %d: %w", i, err)
-						break
-					}
-				}
-			}
-		}
+	fileName := processor.EnsureExtension(result.FileName, fileType)
+	if len(fileName) > maxFileNameLength {
+		return models.Document{}, fmt.Errorf("%w: the remote file name is longer than %d characters", ErrInvalidFileName, maxFileNameLength)
+	}
+	object, err := storage.UploadStream(bytes.NewReader(result.Data), fileName)
+	if err != nil {
+		return models.Document{}, fmt.Errorf("failed to upload file to GCS: %w", err)
+	}
 
-		var finalStatus string
-		var finalError string
-		if processingErr != nil {
-			log.Printf("ERROR: Failed to process document %s: %v", doc.ID, processingErr)
-			finalStatus = "failed"
-			finalError = processingErr.Error()
-		} else {
-			log.Printf("Successfully processed all chunks for document %s", doc.ID)
-			finalStatus = "processed"
-			finalError = ""
+	doc := newDocument(userID, fileName, fileType, object)
+	doc.WorkspaceID = workspaceID
+	doc.SourceURL = rawURL
+	doc, err = createDocumentRecord(doc)
+	if err != nil {
+		if deleteErr := storage.DeleteFile(object.ObjectName); deleteErr != nil {
+			log.Printf("Warning: failed to delete imported file %s after a failed import: %v", object.ObjectName, deleteErr)
 		}
+		return models.Document{}, err
+	}
+
+	go processDocument(doc, bytes.NewReader(result.Data), fileType)
 
-		updateQuery := `UPDATE documents SET status = $1, processing_error = $2 WHERE id = $3`
-		_, err := database.DB.Exec(updateQuery, finalStatus, finalError, doc.ID)
-		if err != nil {
-			log.Printf("ERROR: Failed to update document status for %s: %v", doc.ID, err)
+	return doc, nil
+}
+
+// createDocumentRecord inserts doc with a new ID and the initial "processing" status.
+// A doc with a VersionGroupID becomes the next version of that group, otherwise the first
+// version of a new one.
+func createDocumentRecord(doc models.Document) (models.Document, error) {
+	doc.ID = uuid.NewV4().String()
+	doc.Status = "processing"
+	doc.CreatedAt = time.Now()
+	if doc.VersionGroupID == "" {
+		doc.VersionGroupID = doc.ID
+	}
+	if doc.Metadata == nil {
+		doc.Metadata = map[string]any{}
+	}
+	if doc.CollectionIDs == nil {
+		doc.CollectionIDs = []string{}
+	}
+	if doc.TagIDs == nil {
+		doc.TagIDs = []string{}
+	}
+	metadataJSON, err := json.Marshal(doc.Metadata)
+	if err != nil {
+		return models.Document{}, fmt.Errorf("failed to marshal document metadata: %w", err)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return models.Document{}, err
+	}
+	defer tx.Rollback()
+
+	// Concurrent uploads of new versions would otherwise both take the same next version.
+	if doc.VersionGroupID != doc.ID {
+		if err := lockVersionGroup(tx, doc.VersionGroupID); err != nil {
+			return models.Document{}, err
 		}
-	}()
+	}
+	query := `
+		INSERT INTO documents (id, user_id, file_name, description, metadata, gcs_path, status, content_type, size_bytes, content_hash, parent_document_id, batch_id, folder_path, source_url, created_at, version_group_id, workspace_id, version)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+		        (SELECT COALESCE(MAX(version), 0) + 1 FROM documents WHERE version_group_id = $16))
+		RETURNING version
+	`
+	err = tx.QueryRow(query, doc.ID, nullString(doc.UserID), doc.FileName, nullString(doc.Description), metadataJSON, doc.GCSPath, doc.Status, nullString(doc.ContentType), doc.SizeBytes, nullString(doc.ContentHash), nullString(doc.ParentDocumentID), nullString(doc.BatchID), nullString(doc.FolderPath), nullString(doc.SourceURL), doc.CreatedAt, doc.VersionGroupID, nullString(doc.WorkspaceID)).Scan(&doc.Version)
+	if err != nil {
+		return models.Document{}, fmt.Errorf("failed to create document record: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Document{}, err
+	}
+	return doc, nil
+}
 
+// lockVersionGroup locks the first version of the group, which serializes changes to the
+// group's versions. It returns ErrDocumentNotFound if the group has been deleted.
+func lockVersionGroup(tx *sql.Tx, groupID string) error {
+	var id string
+	err := tx.QueryRow(`SELECT id FROM documents WHERE id = $1 FOR UPDATE`, groupID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return ErrDocumentNotFound
+	}
+	return err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// documentColumns is the column list expected by scanDocument.
+const documentColumns = `id, user_id, workspace_id, file_name, description, metadata, gcs_path, status, processing_error, content_type, COALESCE(size_bytes, 0), content_hash, parent_document_id, batch_id, folder_path, source_url, version_group_id, version,
+	ARRAY(SELECT collection_id FROM document_collections WHERE document_id = documents.version_group_id ORDER BY collection_id),
+	ARRAY(SELECT tag_id FROM document_tags WHERE document_id = documents.version_group_id ORDER BY tag_id),
+	created_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanDocument(row rowScanner) (models.Document, error) {
+	var doc models.Document
+	var userID, workspaceID, description, processingError, contentType, contentHash, parentDocumentID, batchID, folderPath, sourceURL sql.NullString
+	var metadataJSON []byte
+	err := row.Scan(&doc.ID, &userID, &workspaceID, &doc.FileName, &description, &metadataJSON, &doc.GCSPath, &doc.Status, &processingError, &contentType, &doc.SizeBytes, &contentHash, &parentDocumentID, &batchID, &folderPath, &sourceURL, &doc.VersionGroupID, &doc.Version, pq.Array(&doc.CollectionIDs), pq.Array(&doc.TagIDs), &doc.CreatedAt)
+	if err != nil {
+		return models.Document{}, err
+	}
+	if err := json.Unmarshal(metadataJSON, &doc.Metadata); err != nil {
+		return models.Document{}, fmt.Errorf("failed to unmarshal document metadata: %w", err)
+	}
+	doc.UserID = userID.String
+	doc.WorkspaceID = workspaceID.String
+	doc.Description = description.String
+	doc.ProcessingError = processingError.String
+	doc.ContentType = contentType.String
+	doc.ContentHash = contentHash.String
+	doc.ParentDocumentID = parentDocumentID.String
+	doc.BatchID = batchID.String
+	doc.FolderPath = folderPath.String
+	doc.SourceURL = sourceURL.String
 	return doc, nil
 }
 
-func processChunk(chunk string, docID string, chunkIndex int) error {
-	log.Printf("Processing chunk %d for document %s", chunkIndex, docID)
+// processStoredDocument processes a document by reading its file back from GCS, so it
+// doesn't depend on the lifetime of the request that uploaded it.
+func processStoredDocument(doc models.Document, fileType string) {
+	rc, err := storage.OpenFile(getObjectName(doc.GCSPath))
+	if err != nil {
+		setDocumentStatus(doc.ID, fmt.Errorf("failed to read stored file: %w", err))
+		return
+	}
+	defer rc.Close()
 
-	embedding, err := llm.GetEmbedding(chunk)
+	processDocument(doc, rc, fileType)
+}
+
+// processDocument extracts, chunks and embeds the file's content, then records the final status.
+// If the user already has a processed document with identical content, its chunks and
+// embeddings are copied instead.
+func processDocument(doc models.Document, file io.Reader, fileType string) {
+	reused, err := reuseDuplicateChunks(doc, fileType)
 	if err != nil {
-		log.Printf("ERROR: Failed to generate embedding for chunk %d for document %s: %v", chunkIndex, docID, err)
-		return err
+		// Fall back to processing the file normally.
+		log.Printf("Warning: failed to reuse chunks for duplicate document %s: %v", doc.ID, err)
+	}
+	if reused {
+		setDocumentStatus(doc.ID, nil)
+		return
+	}
+
+	setDocumentStatus(doc.ID, extractAndEmbed(doc, file, fileType))
+}
+
+// reuseDuplicateChunks copies the chunks of an earlier processed document with the same
+// content hash into doc. It reports whether a duplicate was found.
+func reuseDuplicateChunks(doc models.Document, fileType string) (bool, error) {
+	// Emails are excluded because their attachments are separate child documents
+	// that copying chunks would not recreate.
+	if doc.ContentHash == "" || fileType == "message/rfc822" || fileType == "application/mbox" {
+		return false, nil
 	}
 
-	chunkModel := models.DocumentChunk{
-		ID:         uuid.NewV4().String(),
-		DocumentID: docID,
-		ChunkIndex: chunkIndex,
-		Content:    chunk,
-		Embedding:  pgvector.NewVector(embedding),
-		CreatedAt:  time.Now(),
+	// Duplicates are looked up in the document's own space: the workspace, or the uploader's
+	// personal documents.
+	var sourceID string
+	findQuery := `
+		SELECT id FROM documents
+		WHERE content_hash = $1 AND status = 'processed' AND id <> $3
+		  AND workspace_id IS NOT DISTINCT FROM $4 AND (workspace_id IS NOT NULL OR user_id = $2)
+		ORDER BY created_at
+		LIMIT 1
+	`
+	err := database.DB.QueryRow(findQuery, doc.ContentHash, doc.UserID, doc.ID, nullString(doc.WorkspaceID)).Scan(&sourceID)
+	if err == sql.ErrNoRows {
+		return false, nil
 	}
-	query := `INSERT INTO document_chunks (id, document_id, chunk_index, content, embedding, created_at) VALUES ($1, $2, $3, $4, $5, $6)`
-	_, err = database.DB.Exec(query, chunkModel.ID, chunkModel.DocumentID, chunkModel.ChunkIndex, chunkModel.Content, chunkModel.Embedding, chunkModel.CreatedAt)
 	if err != nil {
-		log.Printf("ERROR: Failed to save chunk %d for document %s: %v", chunkIndex, docID, err)
+		return false, err
+	}
+
+	// Embeddings from a model migration are copied along with the chunks; the source CTE
+	// pairs each chunk with its new id.
+	copyQuery := `
+		WITH source AS (
+		    SELECT id, gen_random_uuid()::text AS new_id, chunk_index, content, embedding,
+		           embedding_model, embedding_dimensions, metadata
+		    FROM document_chunks
+		    WHERE document_id = $2
+		), copied_chunks AS (
+		    INSERT INTO document_chunks (id, document_id, chunk_index, content, embedding, embedding_model, embedding_dimensions, metadata, created_at)
+		    SELECT new_id, $1, chunk_index, content, embedding, embedding_model, embedding_dimensions, metadata, NOW()
+		    FROM source
+		    RETURNING id
+		), copied_embeddings AS (
+		    INSERT INTO chunk_embeddings (chunk_id, model, dimensions, embedding)
+		    SELECT s.new_id, ce.model, ce.dimensions, ce.embedding
+		    FROM source s
+		    JOIN chunk_embeddings ce ON ce.chunk_id = s.id
+		)
+		SELECT COUNT(*) FROM copied_chunks
+	`
+	var copied int
+	if err := database.DB.QueryRow(copyQuery, doc.ID, sourceID).Scan(&copied); err != nil {
+		return false, err
+	}
+	log.Printf("Document %s has the same content as %s, reused %d chunks", doc.ID, sourceID, copied)
+	return true, nil
+}
+
+// setDocumentStatus marks the document processed, or failed with processingErr.
+func setDocumentStatus(documentID string, processingErr error) {
+	var finalStatus string
+	var finalError string
+	if processingErr != nil {
+		log.Printf("ERROR: Failed to process document %s: %v", documentID, processingErr)
+		finalStatus = "failed"
+		finalError = processingErr.Error()
+	} else {
+		log.Printf("Successfully processed all chunks for document %s", documentID)
+		finalStatus = "processed"
+		finalError = ""
+	}
+
+	updateQuery := `UPDATE documents SET status = $1, processing_error = $2 WHERE id = $3`
+	_, err := database.DB.Exec(updateQuery, finalStatus, finalError, documentID)
+	if err != nil {
+		log.Printf("ERROR: Failed to update document status for %s: %v", documentID, err)
+	}
+}
+
+func extractAndEmbed(doc models.Document, file io.Reader, fileType string) error {
+	pipeline := newChunkPipeline(doc.ID)
+	if err := extractChunks(doc, file, fileType, pipeline); err != nil {
+		// Let in-flight batches finish before reporting the extraction error.
+		pipeline.Wait()
 		return err
 	}
-	log.Printf("Successfully saved chunk %d for document %s", chunkIndex, docID)
-	return nil
+	return pipeline.Wait()
 }
 
-func GetUserDocuments(userID string) ([]models.Document, error) {
-	query := `SELECT id, file_name, gcs_path, status, created_at FROM documents WHERE user_id = $1 ORDER BY created_at DESC`
-	rows, err := database.DB.Query(query, userID)
+// Chunks are chunkSize runes long and overlap by textChunkOverlap runes, or by
+// pdfChunkOverlap in PDFs. storedText relies on this to reassemble a document's text.
+const (
+	chunkSize        = 10000
+	textChunkOverlap = 500
+	pdfChunkOverlap  = 200
+)
+
+// extractChunks extracts and chunks the file's text, feeding the chunks to the pipeline.
+func extractChunks(doc models.Document, file io.Reader, fileType string, pipeline *chunkPipeline) error {
+	switch fileType {
+	case "application/pdf":
+		log.Printf("Starting PDF chunk processing for document %s", doc.ID)
+		return processor.ProcessPDFChunks(file, chunkSize, pdfChunkOverlap, func(chunk string, chunkIndex int, pages processor.PageRange) error {
+			return pipeline.Add(chunk, chunkIndex, map[string]string{
+				"page":     strconv.Itoa(pages.First),
+				"end_page": strconv.Itoa(pages.Last),
+			})
+		})
+	case "message/rfc822", "application/mbox":
+		return processEmails(doc, file, fileType, pipeline)
+	}
+
+	textContent, err := processor.ExtractText(file, fileType)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to extract text: %w", err)
+	}
+	if strings.TrimSpace(textContent) == "" {
+		return processor.ErrNoText
 	}
-	defer rows.Close()
 
-	documents := make([]models.Document, 0)
-	for rows.Next() {
-		var doc models.Document
-		if err := rows.Scan(&doc.ID, &doc.FileName, &doc.GCSPath, &doc.Status, &doc.CreatedAt); err != nil {
-			return nil, err
+	chunks := processor.ChunkText(textContent, chunkSize, textChunkOverlap)
+	for i, chunk := range chunks {
+		if err := pipeline.Add(chunk, i, nil); err != nil {
+			return fmt.Errorf("failed to process chunk %d: %w", i, err)
 		}
-		doc.UserID = userID
-		documents = append(documents, doc)
+	}
+	return nil
+}
+
+// processEmails chunks each message with its headers as chunk metadata, and ingests
+// supported attachments as child documents of doc.
+func processEmails(doc models.Document, file io.Reader, fileType string, pipeline *chunkPipeline) error {
+	messages, err := processor.ExtractEmails(file, fileType)
+	if err != nil {
+		return fmt.Errorf("failed to extract emails: %w", err)
 	}
 
-	return documents, nil
+	chunkIndex := 0
+	for _, msg := range messages {
+		for _, chunk := range processor.ChunkText(msg.Text(), chunkSize, textChunkOverlap) {
+			if err := pipeline.Add(chunk, chunkIndex, msg.Metadata); err != nil {
+				return fmt.Errorf("failed to process chunk %d: %w", chunkIndex, err)
+			}
+			chunkIndex++
+		}
+
+		for _, attachment := range msg.Attachments {
+			// A broken attachment shouldn't fail the email itself; it gets its own failed status.
+			if err := ingestAttachment(doc, attachment); err != nil {
+				log.Printf("Warning: failed to ingest attachment %q of document %s: %v", attachment.FileName, doc.ID, err)
+			}
+		}
+	}
+	return nil
 }
 
-func GetDocumentStatus(documentID, userID string) (models.Document, error) {
-	var doc models.Document
-	var processingError sql.NullString
-	query := `SELECT id, file_name, gcs_path, status, created_at, processing_error FROM documents WHERE id = $1 AND user_id = $2`
-	err := database.DB.QueryRow(query, documentID, userID).Scan(&doc.ID, &doc.FileName, &doc.GCSPath, &doc.Status, &doc.CreatedAt, &processingError)
+func ingestAttachment(parent models.Document, attachment processor.Attachment) error {
+	fileType, err := processor.DetectFileType(sniffHead(attachment.Data), attachment.ContentType, attachment.FileName)
+	if err != nil || !processor.IsSupportedFileType(fileType) {
+		log.Printf("Skipping unsupported attachment %q (%s) of document %s: %v", attachment.FileName, attachment.ContentType, parent.ID, err)
+		return nil
+	}
+
+	object, err := storage.UploadStream(bytes.NewReader(attachment.Data), attachment.FileName)
 	if err != nil {
-		return models.Document{}, err
+		return fmt.Errorf("failed to upload attachment to GCS: %w", err)
 	}
-	if processingError.Valid {
-		doc.ProcessingError = processingError.String
+
+	child := newDocument(parent.UserID, attachment.FileName, fileType, object)
+	child.WorkspaceID = parent.WorkspaceID
+	child.ParentDocumentID = parent.ID
+	child, err = createDocumentRecord(child)
+	if err != nil {
+		return err
 	}
-	doc.UserID = userID
-	return doc, nil
+
+	// Attachments are processed recursively, so an attached email's own attachments
+	// become children of the attachment.
+	processDocument(child, bytes.NewReader(attachment.Data), fileType)
+	return nil
+}
+
+// sniffHead returns the leading bytes of data used for file type detection.
+func sniffHead(data []byte) []byte {
+	if len(data) > processor.SniffLen {
+		return data[:processor.SniffLen]
+	}
+	return data
+}
+
+func GetDocumentStatus(documentID, userID string) (models.Document, error) {
+	return authorizeDocument(documentID, userID, accessView)
 }
 
 func getObjectName(gcsPath string) string {
@@ -160,42 +434,114 @@ func getObjectName(gcsPath string) string {
 }
 
 func DownloadDocument(documentID, userID string) ([]byte, string, error) {
-	var gcsPath, fileName string
-	query := `SELECT gcs_path, file_name FROM documents WHERE id = $1 AND user_id = $2`
-	err := database.DB.QueryRow(query, documentID, userID).Scan(&gcsPath, &fileName)
+	doc, err := authorizeDocument(documentID, userID, accessView)
 	if err != nil {
 		return nil, "", err
 	}
 
-	objectName := getObjectName(gcsPath)
+	objectName := getObjectName(doc.GCSPath)
 	fileContent, err := storage.DownloadFile(objectName)
 	if err != nil {
 		return nil, "", err
 	}
 
-	return fileContent, fileName, nil
+	return fileContent, doc.FileName, nil
 }
 
+// DeleteDocument deletes the document documentID belongs to with all its versions.
 func DeleteDocument(documentID, userID string) error {
-	var gcsPath string
-	query := `SELECT gcs_path FROM documents WHERE id = $1 AND user_id = $2`
-	err := database.DB.QueryRow(query, documentID, userID).Scan(&gcsPath)
+	doc, err := authorizeDocument(documentID, userID, accessOwner)
 	if err != nil {
 		return err
 	}
+	groupID := doc.VersionGroupID
 
-	objectName := getObjectName(gcsPath)
-	err = storage.DeleteFile(objectName)
+	tx, err := database.DB.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	query = `DELETE FROM documents WHERE id = $1`
-	_, err = database.DB.Exec(query, documentID)
-	return err
+	// Locking the group keeps a version uploaded meanwhile from losing its file.
+	if err := lockVersionGroup(tx, groupID); err != nil {
+		return err
+	}
+	versionPaths, err := getVersionGCSPaths(tx, groupID)
+	if err != nil {
+		return err
+	}
+	// Child documents (e.g. email attachments) are removed by the ON DELETE CASCADE,
+	// but their files have to be deleted from GCS.
+	childPaths, err := getDescendantGCSPaths(tx, groupID)
+	if err != nil {
+		return err
+	}
+
+	// Deleting the first version cascades to the later ones.
+	if _, err := tx.Exec(`DELETE FROM documents WHERE id = $1`, groupID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	// The rows are gone, so a file that fails to delete is only left behind in storage.
+	for _, gcsPath := range append(versionPaths, childPaths...) {
+		if err := storage.DeleteFile(getObjectName(gcsPath)); err != nil {
+			log.Printf("Warning: failed to delete file %s of document %s: %v", gcsPath, documentID, err)
+		}
+	}
+	return nil
+}
+
+func getVersionGCSPaths(tx *sql.Tx, groupID string) ([]string, error) {
+	rows, err := tx.Query(`SELECT gcs_path FROM documents WHERE version_group_id = $1`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
+}
+
+// getDescendantGCSPaths returns the files of the documents extracted from any version of
+// the document, recursively.
+func getDescendantGCSPaths(tx *sql.Tx, groupID string) ([]string, error) {
+	query := `
+		WITH RECURSIVE descendants AS (
+			SELECT id, gcs_path FROM documents
+			WHERE parent_document_id IN (SELECT id FROM documents WHERE version_group_id = $1)
+			UNION ALL
+			SELECT d.id, d.gcs_path FROM documents d JOIN descendants ON d.parent_document_id = descendants.id
+		)
+		SELECT gcs_path FROM descendants
+	`
+	rows, err := tx.Query(query, groupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paths []string
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, rows.Err()
 }
 
-func GetDocumentContent(documentID string) (string, error) {
+func getDocumentContent(documentID string) (string, error) {
 	query := `
 		SELECT content
 		FROM document_chunks
@@ -220,27 +566,159 @@ func GetDocumentContent(documentID string) (string, error) {
 	return strings.Join(chunks, "\n\n"), nil
 }
 
-func GetDocumentsByIDs(documentIDs []string, userID string) ([]models.Document, error) {
-	if len(documentIDs) == 0 {
-		return []models.Document{}, nil
+var (
+	// ErrInvalidDocumentUpdate is returned for document updates that fail validation.
+	ErrInvalidDocumentUpdate = errors.New("invalid document update")
+	// ErrInvalidMetadata is returned for malformed document metadata or metadata filters.
+	ErrInvalidMetadata = errors.New("invalid metadata")
+	// ErrInvalidFileName is returned when a new document's file name is too long.
+	ErrInvalidFileName = errors.New("invalid file name")
+)
+
+const (
+	maxFileNameLength         = 255
+	maxDescriptionLength      = 5000
+	maxMetadataFields         = 50
+	maxMetadataKeyLength      = 64
+	maxMetadataValueLength    = 1000
+	metadataKeyAllowedSymbols = "_-. "
+)
+
+// DocumentUpdate is a partial update of a document's descriptive fields. Nil fields are
+// left unchanged. Metadata is merged into the existing fields; a nil value removes a field.
+type DocumentUpdate struct {
+	FileName    *string
+	Description *string
+	Metadata    map[string]any
+}
+
+// UpdateDocument applies update to the latest version of the document documentID belongs
+// to and returns that version. Earlier versions keep their names, descriptions and metadata
+// as they were; new versions carry over the latest ones.
+func UpdateDocument(documentID, userID string, update DocumentUpdate) (models.Document, error) {
+	if update.FileName != nil {
+		name := strings.TrimSpace(*update.FileName)
+		if name == "" || len(name) > maxFileNameLength || strings.ContainsAny(name, "/\\") {
+			return models.Document{}, fmt.Errorf("%w: file_name must be 1 to %d characters without slashes", ErrInvalidDocumentUpdate, maxFileNameLength)
+		}
+		update.FileName = &name
+	}
+	if update.Description != nil && len(*update.Description) > maxDescriptionLength {
+		return models.Document{}, fmt.Errorf("%w: description must be at most %d characters", ErrInvalidDocumentUpdate, maxDescriptionLength)
+	}
+	if err := validateMetadata(update.Metadata, true); err != nil {
+		return models.Document{}, err
 	}
 
-	query := `SELECT id, file_name, gcs_path, status, created_at FROM documents WHERE id = ANY($1) AND user_id = $2`
-	rows, err := database.DB.Query(query, pq.Array(documentIDs), userID)
+	authorized, err := authorizeDocument(documentID, userID, accessOwner)
 	if err != nil {
+		return models.Document{}, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return models.Document{}, err
+	}
+	defer tx.Rollback()
+
+	// Locking the group keeps a new version from being created from the state before this
+	// update, and concurrent metadata merges from overwriting each other.
+	if err := lockVersionGroup(tx, authorized.VersionGroupID); err != nil {
+		return models.Document{}, err
+	}
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE version_group_id = $1 ORDER BY version DESC LIMIT 1`
+	doc, err := scanDocument(tx.QueryRow(query, authorized.VersionGroupID))
+	if err == sql.ErrNoRows {
+		// Deleted since the access check.
+		return models.Document{}, ErrDocumentNotFound
+	} else if err != nil {
+		return models.Document{}, err
+	}
+
+	if update.FileName != nil {
+		doc.FileName = *update.FileName
+	}
+	if update.Description != nil {
+		doc.Description = *update.Description
+	}
+	for key, value := range update.Metadata {
+		if value == nil {
+			delete(doc.Metadata, key)
+		} else {
+			doc.Metadata[key] = value
+		}
+	}
+	if len(doc.Metadata) > maxMetadataFields {
+		return models.Document{}, fmt.Errorf("%w: a document can have at most %d metadata fields", ErrInvalidMetadata, maxMetadataFields)
+	}
+
+	metadataJSON, err := json.Marshal(doc.Metadata)
+	if err != nil {
+		return models.Document{}, fmt.Errorf("failed to marshal document metadata: %w", err)
+	}
+	updateQuery := `UPDATE documents SET file_name = $1, description = $2, metadata = $3 WHERE id = $4`
+	if _, err := tx.Exec(updateQuery, doc.FileName, nullString(doc.Description), metadataJSON, doc.ID); err != nil {
+		return models.Document{}, fmt.Errorf("failed to update document: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Document{}, err
+	}
+	return doc, nil
+}
+
+// ParseMetadataFilter parses a JSON object of metadata fields to filter documents by.
+// Documents match when they have all the fields with equal values of the same type.
+func ParseMetadataFilter(raw string) (map[string]any, error) {
+	var filter map[string]any
+	if err := json.Unmarshal([]byte(raw), &filter); err != nil {
+		return nil, fmt.Errorf("%w: metadata filter must be a JSON object", ErrInvalidMetadata)
+	}
+	if err := ValidateMetadataFilter(filter); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return filter, nil
+}
 
-	documents := make([]models.Document, 0)
-	for rows.Next() {
-		var doc models.Document
-		if err := rows.Scan(&doc.ID, &doc.FileName, &doc.GCSPath, &doc.Status, &doc.CreatedAt); err != nil {
-			return nil, err
+// ValidateMetadataFilter checks a metadata filter, which must not contain null values.
+func ValidateMetadataFilter(filter map[string]any) error {
+	return validateMetadata(filter, false)
+}
+
+// validateMetadata checks that keys are short identifiers and values are strings, numbers
+// or booleans. Nil values are only valid when allowNull is set.
+func validateMetadata(metadata map[string]any, allowNull bool) error {
+	if len(metadata) > maxMetadataFields {
+		return fmt.Errorf("%w: a document can have at most %d metadata fields", ErrInvalidMetadata, maxMetadataFields)
+	}
+	for key, value := range metadata {
+		if !validMetadataKey(key) {
+			return fmt.Errorf("%w: metadata key %q must be 1 to %d letters, digits or %q", ErrInvalidMetadata, key, maxMetadataKeyLength, metadataKeyAllowedSymbols)
+		}
+		switch v := value.(type) {
+		case string:
+			if len(v) > maxMetadataValueLength {
+				return fmt.Errorf("%w: metadata value of %q must be at most %d characters", ErrInvalidMetadata, key, maxMetadataValueLength)
+			}
+		case float64, bool:
+		case nil:
+			if !allowNull {
+				return fmt.Errorf("%w: metadata value of %q must not be null", ErrInvalidMetadata, key)
+			}
+		default:
+			return fmt.Errorf("%w: metadata value of %q must be a string, number or boolean", ErrInvalidMetadata, key)
 		}
-		doc.UserID = userID
-		documents = append(documents, doc)
 	}
+	return nil
+}
 
-	return documents, nil
+func validMetadataKey(key string) bool {
+	if key == "" || len(key) > maxMetadataKeyLength {
+		return false
+	}
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(metadataKeyAllowedSymbols, r) {
+			return false
+		}
+	}
+	return true
 }
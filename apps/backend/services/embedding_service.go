@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/internal/llm"
+	"strings"
+	"sync/atomic"
+
+	"github.com/lib/pq"
+	"github.com/pgvector/pgvector-go"
+)
+
+var (
+	embeddingCacheHits   atomic.Int64
+	embeddingCacheMisses atomic.Int64
+)
+
+// EmbeddingCacheStats reports how often embeddings were served from the cache since startup.
+type EmbeddingCacheStats struct {
+	Hits    int64   `json:"hits"`
+	Misses  int64   `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+func GetEmbeddingCacheStats() EmbeddingCacheStats {
+	stats := EmbeddingCacheStats{Hits: embeddingCacheHits.Load(), Misses: embeddingCacheMisses.Load()}
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats
+}
+
+// activeEmbeddingModel is the model chunks are embedded and searched with.
+func activeEmbeddingModel() llm.EmbeddingModel {
+	return llm.EmbeddingModel{Name: config.AppConfig.EmbeddingModel, Dimensions: int(config.AppConfig.EmbeddingDimensions)}
+}
+
+// nextEmbeddingModel is the model being migrated to, if any. New chunks are embedded with
+// it as well so the backfill doesn't have to chase them.
+func nextEmbeddingModel() (llm.EmbeddingModel, bool) {
+	next := llm.EmbeddingModel{Name: config.AppConfig.EmbeddingNextModel, Dimensions: int(config.AppConfig.EmbeddingNextDimensions)}
+	return next, next.Name != "" && next != activeEmbeddingModel()
+}
+
+// getEmbedding returns the embedding for a single text, see getEmbeddings.
+func getEmbedding(model llm.EmbeddingModel, text string) ([]float32, error) {
+	embeddings, err := getEmbeddings(context.Background(), model, []string{text})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+// getEmbeddings returns one embedding per text, consulting the embedding cache before
+// calling the provider, which is only asked for the misses in a single batch. Cache errors
+// are logged and treated as misses.
+func getEmbeddings(ctx context.Context, model llm.EmbeddingModel, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, len(texts))
+	hashes := make([]string, len(texts))
+	for i, text := range texts {
+		hashes[i] = hashText(text)
+	}
+
+	cached, err := readEmbeddingCache(model, hashes)
+	if err != nil {
+		log.Printf("Warning: failed to read embedding cache: %v", err)
+	}
+
+	var missTexts, missHashes []string
+	var missIndexes []int
+	for i, hash := range hashes {
+		if embedding, ok := cached[hash]; ok {
+			embeddings[i] = embedding
+			continue
+		}
+		missTexts = append(missTexts, texts[i])
+		missHashes = append(missHashes, hash)
+		missIndexes = append(missIndexes, i)
+	}
+	embeddingCacheHits.Add(int64(len(texts) - len(missTexts)))
+	embeddingCacheMisses.Add(int64(len(missTexts)))
+
+	if len(missTexts) == 0 {
+		return embeddings, nil
+	}
+
+	generated, err := llm.GetEmbeddings(ctx, model, missTexts)
+	if err != nil {
+		return nil, err
+	}
+	for i, embedding := range generated {
+		embeddings[missIndexes[i]] = embedding
+	}
+
+	if err := writeEmbeddingCache(model, missHashes, generated); err != nil {
+		log.Printf("Warning: failed to write embedding cache: %v", err)
+	}
+	return embeddings, nil
+}
+
+// embedChunksWith embeds the chunks with a model other than the one recorded on them and
+// stores the results in chunk_embeddings.
+func embedChunksWith(ctx context.Context, model llm.EmbeddingModel, chunkIDs, texts []string) error {
+	embeddings, err := getEmbeddings(ctx, model, texts)
+	if err != nil {
+		return err
+	}
+
+	var query strings.Builder
+	query.WriteString(`INSERT INTO chunk_embeddings (chunk_id, model, dimensions, embedding) VALUES `)
+	args := []any{model.Name, model.Dimensions}
+	for i, chunkID := range chunkIDs {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "($%d, $1, $2, $%d)", len(args)+1, len(args)+2)
+		args = append(args, chunkID, pgvector.NewVector(embeddings[i]))
+	}
+	query.WriteString(` ON CONFLICT (chunk_id, model, dimensions) DO UPDATE SET embedding = EXCLUDED.embedding`)
+
+	_, err = database.DB.Exec(query.String(), args...)
+	return err
+}
+
+// BackfillEmbeddings embeds every chunk that has no embedding for model yet, in batches of
+// batchSize, and returns how many chunks it embedded. Chunks are visited in id order, so an
+// interrupted run can simply be started again. Chunks created while it runs are covered by
+// configuring model as the next embedding model before starting.
+func BackfillEmbeddings(model llm.EmbeddingModel, batchSize int) (int, error) {
+	query := `
+		SELECT dc.id, dc.content
+		FROM document_chunks dc
+		WHERE dc.id > $3
+		  AND NOT (dc.embedding IS NOT NULL AND dc.embedding_model IS NOT DISTINCT FROM $1 AND dc.embedding_dimensions IS NOT DISTINCT FROM $2)
+		  AND NOT EXISTS (
+		      SELECT 1 FROM chunk_embeddings ce
+		      WHERE ce.chunk_id = dc.id AND ce.model = $1 AND ce.dimensions = $2
+		  )
+		ORDER BY dc.id
+		LIMIT $4
+	`
+
+	embedded := 0
+	lastID := ""
+	for {
+		rows, err := database.DB.Query(query, model.Name, model.Dimensions, lastID, batchSize)
+		if err != nil {
+			return embedded, fmt.Errorf("failed to query chunks to backfill: %w", err)
+		}
+		var chunkIDs, texts []string
+		for rows.Next() {
+			var id, content string
+			if err := rows.Scan(&id, &content); err != nil {
+				rows.Close()
+				return embedded, err
+			}
+			chunkIDs = append(chunkIDs, id)
+			texts = append(texts, content)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return embedded, err
+		}
+		if len(chunkIDs) == 0 {
+			return embedded, nil
+		}
+
+		if err := embedChunksWith(context.Background(), model, chunkIDs, texts); err != nil {
+			return embedded, fmt.Errorf("failed to embed chunks after %q: %w", lastID, err)
+		}
+		embedded += len(chunkIDs)
+		lastID = chunkIDs[len(chunkIDs)-1]
+		log.Printf("Backfilled %s embeddings for %d chunks", model, embedded)
+	}
+}
+
+func readEmbeddingCache(model llm.EmbeddingModel, hashes []string) (map[string][]float32, error) {
+	cached := make(map[string][]float32)
+	query := `SELECT text_hash, embedding FROM embedding_cache WHERE model = $1 AND dimensions = $2 AND text_hash = ANY($3)`
+	rows, err := database.DB.Query(query, model.Name, model.Dimensions, pq.Array(hashes))
+	if err != nil {
+		return cached, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		var embedding pgvector.Vector
+		if err := rows.Scan(&hash, &embedding); err != nil {
+			return cached, err
+		}
+		cached[hash] = embedding.Slice()
+	}
+	return cached, rows.Err()
+}
+
+func writeEmbeddingCache(model llm.EmbeddingModel, hashes []string, embeddings [][]float32) error {
+	var query strings.Builder
+	query.WriteString(`INSERT INTO embedding_cache (model, dimensions, text_hash, embedding) VALUES `)
+	args := []any{model.Name, model.Dimensions}
+	for i, hash := range hashes {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+		fmt.Fprintf(&query, "($1, $2, $%d, $%d)", len(args)+1, len(args)+2)
+		args = append(args, hash, pgvector.NewVector(embeddings[i]))
+	}
+	// Duplicate texts within one batch would otherwise violate the primary key.
+	query.WriteString(` ON CONFLICT DO NOTHING`)
+
+	_, err := database.DB.Exec(query.String(), args...)
+	return err
+}
+
+func hashText(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
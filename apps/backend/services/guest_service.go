@@ -0,0 +1,200 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/internal/storage"
+	"strategic-insight-analyst/backend/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// AuthMethodGuest is the auth method of users who signed in anonymously.
+const AuthMethodGuest = "guest"
+
+// ErrGuestExpired is returned for requests of guests whose account has expired but hasn't
+// been deleted yet.
+var ErrGuestExpired = errors.New("the guest account has expired")
+
+// GuestExpiresAt returns when the user's guest account expires, or nil if the user isn't a
+// guest or guest accounts are kept.
+func GuestExpiresAt(user *models.User) *time.Time {
+	if user.AuthMethod != AuthMethodGuest || config.AppConfig.GuestAccountTTL <= 0 {
+		return nil
+	}
+	expiresAt := user.CreatedAt.Add(config.AppConfig.GuestAccountTTL)
+	return &expiresAt
+}
+
+// CheckGuestExpiry returns ErrGuestExpired if the user is a guest past their expiry.
+func CheckGuestExpiry(user *models.User) error {
+	if expiresAt := GuestExpiresAt(user); expiresAt != nil && time.Now().After(*expiresAt) {
+		return ErrGuestExpired
+	}
+	return nil
+}
+
+// StartGuestCleanup periodically deletes expired guest accounts with their documents,
+// unless guest accounts are kept.
+func StartGuestCleanup(interval time.Duration) {
+	if config.AppConfig.GuestAccountTTL <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := deleteExpiredGuests(); err != nil {
+				log.Printf("ERROR: Failed to clean up expired guest accounts: %v", err)
+			}
+		}
+	}()
+}
+
+func deleteExpiredGuests() error {
+	cutoff := time.Now().Add(-config.AppConfig.GuestAccountTTL)
+	rows, err := database.DB.Query(`SELECT id FROM users WHERE auth_method = $1 AND created_at < $2`, AuthMethodGuest, cutoff)
+	if err != nil {
+		return err
+	}
+	var userIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		userIDs = append(userIDs, id)
+	}
+	rows.Close()
+
+	deleted := 0
+	for _, id := range userIDs {
+		ok, err := deleteGuest(id)
+		if err != nil {
+			return err
+		}
+		if ok {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		log.Printf("Deleted %d expired guest accounts", deleted)
+	}
+	return nil
+}
+
+// deleteGuest deletes a guest with everything they own. Their documents in workspaces with
+// other members are handed over to a workspace owner, or another member, so the team keeps
+// them; the workspaces no one else is a member of are deleted with all their documents.
+// Their personal documents are deleted before the user, see documents_uploader_check, and
+// their other rows go with the user by the ON DELETE CASCADE. Files are deleted from GCS
+// once the transaction has committed. It reports false if the user is no longer a guest.
+func deleteGuest(userID string) (bool, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Locking the user keeps them from being upgraded while their documents are deleted.
+	var locked bool
+	err = tx.QueryRow(`SELECT true FROM users WHERE id = $1 AND auth_method = $2 FOR UPDATE`, userID, AuthMethodGuest).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	workspacesQuery := `
+		SELECT workspace_id FROM workspace_members m
+		WHERE user_id = $1
+		  AND NOT EXISTS (SELECT 1 FROM workspace_members o WHERE o.workspace_id = m.workspace_id AND o.user_id <> $1)
+	`
+	workspaceIDs, err := queryStrings(tx, workspacesQuery, userID)
+	if err != nil {
+		return false, err
+	}
+	// The guest's personal documents, the documents of their workspaces, the later versions
+	// of either and the documents extracted from them, recursively.
+	doomedCondition := `(user_id = $1 AND workspace_id IS NULL) OR workspace_id = ANY($2)`
+	documentsQuery := `
+		WITH RECURSIVE doomed AS (
+			SELECT id, gcs_path FROM documents
+			WHERE ` + doomedCondition + ` OR version_group_id IN (SELECT id FROM documents WHERE ` + doomedCondition + `)
+			UNION
+			SELECT d.id, d.gcs_path FROM documents d JOIN doomed ON d.parent_document_id = doomed.id
+		)
+		SELECT gcs_path FROM doomed
+	`
+	documentPaths, err := queryStrings(tx, documentsQuery, userID, pq.Array(workspaceIDs))
+	if err != nil {
+		return false, err
+	}
+	partsQuery := `
+		SELECT p.object_name FROM resumable_upload_parts p
+		JOIN resumable_uploads u ON u.id = p.upload_id
+		WHERE u.user_id = $1
+	`
+	partObjects, err := queryStrings(tx, partsQuery, userID)
+	if err != nil {
+		return false, err
+	}
+
+	handOverQuery := `
+		UPDATE documents d SET user_id = (
+			SELECT m.user_id FROM workspace_members m
+			WHERE m.workspace_id = d.workspace_id AND m.user_id <> $1
+			ORDER BY m.role = $3 DESC, m.created_at
+			LIMIT 1
+		)
+		WHERE d.user_id = $1 AND d.workspace_id IS NOT NULL AND NOT d.workspace_id = ANY($2)
+	`
+	if _, err := tx.Exec(handOverQuery, userID, pq.Array(workspaceIDs), RoleOwner); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM documents WHERE `+doomedCondition, userID, pq.Array(workspaceIDs)); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM users WHERE id = $1`, userID); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`DELETE FROM workspaces WHERE id = ANY($1)`, pq.Array(workspaceIDs)); err != nil {
+		return false, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	for _, gcsPath := range documentPaths {
+		if err := storage.DeleteFile(getObjectName(gcsPath)); err != nil {
+			log.Printf("Warning: failed to delete file %s of expired guest %s: %v", gcsPath, userID, err)
+		}
+	}
+	for _, objectName := range partObjects {
+		deleteUploadPart(objectName)
+	}
+	return true, nil
+}
+
+func queryStrings(tx *sql.Tx, query string, args ...any) ([]string, error) {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []string
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, rows.Err()
+}
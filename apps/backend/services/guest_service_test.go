@@ -0,0 +1,54 @@
+package services
+
+import (
+	"database/sql"
+	"strategic-insight-analyst/backend/database"
+	"testing"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+func TestDeleteGuestKeepsTeamDocuments(t *testing.T) {
+	f := newAccessFixture(t)
+	guest := newTestUser(t)
+	mustExec(t, `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`, f.workspaceID, guest, RoleEditor)
+	soloWorkspaceID := uuid.NewV4().String()
+	mustExec(t, `INSERT INTO workspaces (id, name, created_by) VALUES ($1, 'Solo workspace', $2)`, soloWorkspaceID, guest)
+	mustExec(t, `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`, soloWorkspaceID, guest, RoleOwner)
+	t.Cleanup(func() {
+		database.DB.Exec(`DELETE FROM documents WHERE workspace_id = $1`, soloWorkspaceID)
+		database.DB.Exec(`DELETE FROM workspaces WHERE id = $1`, soloWorkspaceID)
+	})
+
+	personal := newTestDocument(t, guest, "")
+	team := newTestDocument(t, guest, f.workspaceID)
+	solo := newTestDocument(t, guest, soloWorkspaceID)
+
+	deleted, err := deleteGuest(guest)
+	if err != nil {
+		t.Fatalf("deleteGuest() error = %v", err)
+	}
+	if !deleted {
+		t.Fatal("deleteGuest() didn't delete the guest")
+	}
+
+	var uploader sql.NullString
+	if err := database.DB.QueryRow(`SELECT user_id FROM documents WHERE id = $1`, team.ID).Scan(&uploader); err != nil {
+		t.Fatalf("document in a shared workspace: %v", err)
+	}
+	if uploader.String != f.owner {
+		t.Errorf("document in a shared workspace belongs to %q, want the workspace owner %q", uploader.String, f.owner)
+	}
+	for name, id := range map[string]string{"personal document": personal.ID, "document in a solo workspace": solo.ID} {
+		if err := database.DB.QueryRow(`SELECT user_id FROM documents WHERE id = $1`, id).Scan(&uploader); err != sql.ErrNoRows {
+			t.Errorf("%s: error = %v, want it deleted", name, err)
+		}
+	}
+	var exists bool
+	if err := database.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM workspaces WHERE id = $1)`, soloWorkspaceID).Scan(&exists); err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Error("the guest's solo workspace wasn't deleted")
+	}
+}
@@ -0,0 +1,385 @@
+package services
+
+import (
+	"bufio"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strategic-insight-analyst/backend/config"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/internal/processor"
+	"strategic-insight-analyst/backend/internal/storage"
+	"strategic-insight-analyst/backend/models"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrUploadOffsetMismatch is returned when a PATCH doesn't start at the upload's current offset.
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+	// ErrUploadTooLarge is returned when an upload exceeds its declared size or the upload limit.
+	ErrUploadTooLarge = errors.New("upload exceeds the allowed size")
+	// ErrUploadIncomplete is returned when finalizing an upload that hasn't received all its bytes.
+	ErrUploadIncomplete = errors.New("upload is incomplete")
+	// ErrUploadFinalizing is returned for changes to an upload that is being finalized.
+	ErrUploadFinalizing = errors.New("upload is already being finalized")
+)
+
+// Resumable upload statuses. Finalizing moves an upload from open to finalizing exactly
+// once, so concurrent finalize calls can't create the document twice.
+const (
+	uploadOpen       = "open"
+	uploadFinalizing = "finalizing"
+)
+
+// CreateResumableUpload starts a resumable upload of sizeBytes bytes.
+func CreateResumableUpload(userID, fileName string, sizeBytes int64) (models.ResumableUpload, error) {
+	if sizeBytes > config.AppConfig.MaxUploadBytes {
+		return models.ResumableUpload{}, fmt.Errorf("%w: the limit is %d MB", ErrUploadTooLarge, config.AppConfig.MaxUploadBytes>>20)
+	}
+
+	upload := models.ResumableUpload{
+		ID:        uuid.NewV4().String(),
+		UserID:    userID,
+		FileName:  fileName,
+		SizeBytes: sizeBytes,
+		CreatedAt: time.Now(),
+	}
+	upload.ExpiresAt = upload.CreatedAt.Add(config.AppConfig.ResumableUploadTTL)
+
+	query := `INSERT INTO resumable_uploads (id, user_id, file_name, size_bytes, offset_bytes, expires_at, created_at) VALUES ($1, $2, $3, $4, 0, $5, $6)`
+	if _, err := database.DB.Exec(query, upload.ID, upload.UserID, upload.FileName, upload.SizeBytes, upload.ExpiresAt, upload.CreatedAt); err != nil {
+		return models.ResumableUpload{}, fmt.Errorf("failed to create resumable upload: %w", err)
+	}
+	return upload, nil
+}
+
+// GetResumableUpload returns an unexpired upload owned by userID, or sql.ErrNoRows.
+func GetResumableUpload(uploadID, userID string) (models.ResumableUpload, error) {
+	var upload models.ResumableUpload
+	var fileType sql.NullString
+	if err := authorizeOwner("resumable_uploads", uploadID, userID); err != nil {
+		return models.ResumableUpload{}, err
+	}
+	query := `SELECT id, user_id, file_name, file_type, size_bytes, offset_bytes, status, expires_at, created_at FROM resumable_uploads WHERE id = $1 AND expires_at > NOW()`
+	err := database.DB.QueryRow(query, uploadID).Scan(&upload.ID, &upload.UserID, &upload.FileName, &fileType, &upload.SizeBytes, &upload.OffsetBytes, &upload.Status, &upload.ExpiresAt, &upload.CreatedAt)
+	if err != nil {
+		return models.ResumableUpload{}, err
+	}
+	upload.FileType = fileType.String
+	return upload, nil
+}
+
+// AppendResumableUpload stores the bytes in body as the part starting at offset. The file
+// type is detected from the first part so unsupported files are rejected before the rest
+// is sent. If the body breaks off, the bytes received until then are kept and the offset
+// advanced, so the client can resume from there.
+func AppendResumableUpload(uploadID, userID string, offset int64, body io.Reader) (models.ResumableUpload, error) {
+	upload, err := GetResumableUpload(uploadID, userID)
+	if err != nil {
+		return models.ResumableUpload{}, err
+	}
+	if upload.Status != uploadOpen {
+		return upload, ErrUploadFinalizing
+	}
+	if offset != upload.OffsetBytes {
+		return upload, fmt.Errorf("%w: expected offset %d", ErrUploadOffsetMismatch, upload.OffsetBytes)
+	}
+
+	remaining := upload.SizeBytes - upload.OffsetBytes
+	interruptible := &interruptibleReader{r: body}
+	br := bufio.NewReaderSize(io.LimitReader(interruptible, remaining+1), processor.SniffLen)
+	if offset == 0 {
+		head, err := br.Peek(processor.SniffLen)
+		if err != nil && err != io.EOF {
+			return upload, fmt.Errorf("failed to read upload body: %w", err)
+		}
+		upload.FileType, err = processor.DetectFileType(head, "", upload.FileName)
+		if err != nil {
+			return upload, err
+		}
+	}
+
+	// Each attempt writes its own object, so a PATCH losing a race for the offset only ever
+	// deletes what it wrote itself.
+	objectName := fmt.Sprintf("resumable-uploads/%s/%020d-%s", upload.ID, offset, uuid.NewV4().String())
+	size, err := storage.WriteObject(objectName, br)
+	if err != nil {
+		return upload, fmt.Errorf("failed to store upload part: %w", err)
+	}
+	if size > remaining {
+		deleteUploadPart(objectName)
+		return upload, fmt.Errorf("%w: only %d more bytes were declared", ErrUploadTooLarge, remaining)
+	}
+	if size == 0 {
+		deleteUploadPart(objectName)
+		if interruptible.err != nil {
+			return upload, fmt.Errorf("failed to read upload body: %w", interruptible.err)
+		}
+		return upload, nil
+	}
+	if interruptible.err != nil {
+		log.Printf("Upload %s was interrupted after %d bytes at offset %d: %v", upload.ID, size, offset, interruptible.err)
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		deleteUploadPart(objectName)
+		return upload, err
+	}
+	defer tx.Rollback()
+
+	// The offset condition guards against concurrent PATCHes for the same range.
+	updateQuery := `UPDATE resumable_uploads SET offset_bytes = offset_bytes + $1, file_type = COALESCE(file_type, NULLIF($2, '')), expires_at = $3 WHERE id = $4 AND offset_bytes = $5 AND status = $6`
+	result, err := tx.Exec(updateQuery, size, upload.FileType, time.Now().Add(config.AppConfig.ResumableUploadTTL), upload.ID, offset, uploadOpen)
+	if err != nil {
+		deleteUploadPart(objectName)
+		return upload, fmt.Errorf("failed to update upload offset: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		deleteUploadPart(objectName)
+		return upload, ErrUploadOffsetMismatch
+	}
+
+	partQuery := `INSERT INTO resumable_upload_parts (upload_id, offset_bytes, size_bytes, object_name) VALUES ($1, $2, $3, $4)`
+	if _, err := tx.Exec(partQuery, upload.ID, offset, size, objectName); err != nil {
+		deleteUploadPart(objectName)
+		return upload, fmt.Errorf("failed to record upload part: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		deleteUploadPart(objectName)
+		return upload, err
+	}
+
+	return GetResumableUpload(uploadID, userID)
+}
+
+// FinalizeResumableUpload concatenates the parts of a complete upload into a single
+// object. It returns the stored object and its detected type, ready to be handed to
+// ProcessAndSaveDocument or ProcessZipUpload. Only one call can finalize an upload; others
+// get ErrUploadFinalizing. The parts are kept until the caller has created the document
+// and calls CompleteResumableUpload, or calls ReopenResumableUpload if it couldn't, so a
+// failed finalize can be retried without sending the file again.
+func FinalizeResumableUpload(uploadID, userID string) (storage.UploadedObject, models.ResumableUpload, error) {
+	upload, err := GetResumableUpload(uploadID, userID)
+	if err != nil {
+		return storage.UploadedObject{}, models.ResumableUpload{}, err
+	}
+	if upload.OffsetBytes != upload.SizeBytes {
+		return storage.UploadedObject{}, upload, fmt.Errorf("%w: received %d of %d bytes", ErrUploadIncomplete, upload.OffsetBytes, upload.SizeBytes)
+	}
+
+	claimQuery := `UPDATE resumable_uploads SET status = $2 WHERE id = $1 AND status = $3 AND offset_bytes = size_bytes`
+	result, err := database.DB.Exec(claimQuery, upload.ID, uploadFinalizing, uploadOpen)
+	if err != nil {
+		return storage.UploadedObject{}, upload, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return storage.UploadedObject{}, upload, ErrUploadFinalizing
+	}
+
+	parts, err := getUploadParts(upload.ID)
+	if err != nil {
+		ReopenResumableUpload(upload.ID)
+		return storage.UploadedObject{}, upload, err
+	}
+
+	reader := &partsReader{parts: parts}
+	defer reader.Close()
+	object, err := storage.UploadStream(reader, upload.FileName)
+	if err != nil {
+		ReopenResumableUpload(upload.ID)
+		return storage.UploadedObject{}, upload, fmt.Errorf("failed to assemble upload: %w", err)
+	}
+	return object, upload, nil
+}
+
+// CompleteResumableUpload removes a finalized upload and its parts once its file has been
+// handed over to a document or batch.
+func CompleteResumableUpload(uploadID string) {
+	parts, err := getUploadParts(uploadID)
+	if err == nil {
+		err = deleteResumableUpload(uploadID, parts)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to clean up resumable upload %s: %v", uploadID, err)
+	}
+}
+
+// ReopenResumableUpload returns an upload being finalized to the open state, so finalizing
+// can be retried after a failure.
+func ReopenResumableUpload(uploadID string) {
+	if _, err := database.DB.Exec(`UPDATE resumable_uploads SET status = $2 WHERE id = $1`, uploadID, uploadOpen); err != nil {
+		log.Printf("Warning: failed to reopen resumable upload %s: %v", uploadID, err)
+	}
+}
+
+// AbortResumableUpload deletes an upload and its stored parts, unless it is being finalized.
+func AbortResumableUpload(uploadID, userID string) error {
+	upload, err := GetResumableUpload(uploadID, userID)
+	if err != nil {
+		return err
+	}
+	parts, err := getUploadParts(upload.ID)
+	if err != nil {
+		return err
+	}
+	// Deleting only while the upload is open keeps an abort from racing a finalize.
+	result, err := database.DB.Exec(`DELETE FROM resumable_uploads WHERE id = $1 AND status = $2`, upload.ID, uploadOpen)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrUploadFinalizing
+	}
+	for _, part := range parts {
+		deleteUploadPart(part.objectName)
+	}
+	return nil
+}
+
+// StartResumableUploadCleanup periodically deletes uploads that have passed their expiry.
+func StartResumableUploadCleanup(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if err := deleteExpiredUploads(); err != nil {
+				log.Printf("ERROR: Failed to clean up expired resumable uploads: %v", err)
+			}
+		}
+	}()
+}
+
+func deleteExpiredUploads() error {
+	rows, err := database.DB.Query(`SELECT id FROM resumable_uploads WHERE expires_at <= NOW()`)
+	if err != nil {
+		return err
+	}
+	var uploadIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		uploadIDs = append(uploadIDs, id)
+	}
+	rows.Close()
+
+	for _, id := range uploadIDs {
+		parts, err := getUploadParts(id)
+		if err != nil {
+			return err
+		}
+		if err := deleteResumableUpload(id, parts); err != nil {
+			return err
+		}
+	}
+	if len(uploadIDs) > 0 {
+		log.Printf("Deleted %d expired resumable uploads", len(uploadIDs))
+	}
+	return nil
+}
+
+type uploadPart struct {
+	offset     int64
+	size       int64
+	objectName string
+}
+
+func getUploadParts(uploadID string) ([]uploadPart, error) {
+	rows, err := database.DB.Query(`SELECT offset_bytes, size_bytes, object_name FROM resumable_upload_parts WHERE upload_id = $1 ORDER BY offset_bytes`, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []uploadPart
+	for rows.Next() {
+		var part uploadPart
+		if err := rows.Scan(&part.offset, &part.size, &part.objectName); err != nil {
+			return nil, err
+		}
+		parts = append(parts, part)
+	}
+	return parts, rows.Err()
+}
+
+func deleteResumableUpload(uploadID string, parts []uploadPart) error {
+	for _, part := range parts {
+		deleteUploadPart(part.objectName)
+	}
+	_, err := database.DB.Exec(`DELETE FROM resumable_uploads WHERE id = $1`, uploadID)
+	return err
+}
+
+func deleteUploadPart(objectName string) {
+	if err := storage.DeleteFile(objectName); err != nil {
+		log.Printf("Warning: failed to delete upload part %s: %v", objectName, err)
+	}
+}
+
+// interruptibleReader ends the stream at the first read error, recording it, so the
+// bytes read before a client disconnects are still stored.
+type interruptibleReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *interruptibleReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, io.EOF
+	}
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		r.err = err
+		err = io.EOF
+	}
+	return n, err
+}
+
+// partsReader reads the stored parts of an upload in order, opening one at a time.
+type partsReader struct {
+	parts   []uploadPart
+	current io.ReadCloser
+}
+
+func (r *partsReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := storage.OpenFile(r.parts[0].objectName)
+			if err != nil {
+				return 0, err
+			}
+			r.current = rc
+			r.parts = r.parts[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n > 0 {
+				return n, nil
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (r *partsReader) Close() error {
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
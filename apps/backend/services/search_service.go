@@ -0,0 +1,137 @@
+package services
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strategic-insight-analyst/backend/internal/highlight"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pgvector/pgvector-go"
+)
+
+// ErrInvalidSearch is returned for search requests that fail validation, including
+// malformed cursors.
+var ErrInvalidSearch = errors.New("invalid search")
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+	maxSearchQueryLength  = 1000
+	snippetLength         = 300
+)
+
+// SearchOptions scopes and pages a search.
+type SearchOptions struct {
+	DocumentFilter
+	// Limit defaults to defaultSearchPageSize and is capped at maxSearchPageSize.
+	Limit int
+	// Cursor is the NextCursor of the previous page.
+	Cursor string
+}
+
+// SearchResult is a chunk matching a search. Page and EndPage are the PDF pages the chunk
+// spans; they are zero for other documents.
+type SearchResult struct {
+	DocumentID string  `json:"document_id"`
+	FileName   string  `json:"file_name"`
+	ChunkIndex int     `json:"chunk_index"`
+	Page       int     `json:"page,omitempty"`
+	EndPage    int     `json:"end_page,omitempty"`
+	Snippet    string  `json:"snippet"`
+	Score      float64 `json:"score"`
+}
+
+// SearchPage is one page of search results, best first. NextCursor is empty on the last page.
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// searchCursor points after the last result of a page. Results are ranked by distance,
+// ties broken by chunk ID.
+type searchCursor struct {
+	Distance float64 `json:"d"`
+	ChunkID  string  `json:"c"`
+}
+
+// SearchDocuments ranks the chunks of the latest versions of the processed documents the
+// user may view by semantic similarity to query. Scores are cosine similarities, higher being closer.
+// Snippets are HTML with the query's words wrapped in <mark> tags.
+func SearchDocuments(userID, query string, opts SearchOptions) (SearchPage, error) {
+	query = strings.TrimSpace(query)
+	if query == "" || utf8.RuneCountInString(query) > maxSearchQueryLength {
+		return SearchPage{}, fmt.Errorf("%w: the query must be 1 to %d characters", ErrInvalidSearch, maxSearchQueryLength)
+	}
+	if opts.Limit <= 0 {
+		opts.Limit = defaultSearchPageSize
+	}
+	opts.Limit = min(opts.Limit, maxSearchPageSize)
+	var cursor *searchCursor
+	if opts.Cursor != "" {
+		cursor = new(searchCursor)
+		data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+		if err != nil || json.Unmarshal(data, cursor) != nil || cursor.ChunkID == "" {
+			return SearchPage{}, fmt.Errorf("%w: malformed cursor", ErrInvalidSearch)
+		}
+	}
+
+	model := activeEmbeddingModel()
+	queryEmbedding, err := getEmbedding(model, query)
+	if err != nil {
+		return SearchPage{}, err
+	}
+
+	// One extra row tells whether there is a next page.
+	args := []any{userID, pgvector.NewVector(queryEmbedding), model.Name, opts.Limit + 1}
+	conditions, args, err := opts.conditions("d", args)
+	if err != nil {
+		return SearchPage{}, err
+	}
+	afterArg := 0
+	if cursor != nil {
+		args = append(args, cursor.Distance, cursor.ChunkID)
+		afterArg = len(args) - 1
+	}
+
+	where := accessibleCondition("d", accessView) + ` AND d.status = 'processed'` + conditions + `
+		  AND NOT EXISTS (
+		      SELECT 1 FROM documents newer
+		      WHERE newer.version_group_id = d.version_group_id AND newer.version > d.version
+		  )`
+	columns := `d.id, d.file_name, dc.chunk_index, dc.content, dc.metadata->>'page', dc.metadata->>'end_page'`
+	sqlQuery := nearestChunksQuery(model, columns, where, afterArg, "$4")
+
+	page := SearchPage{Results: make([]SearchResult, 0, opts.Limit)}
+	var last searchCursor
+	err = queryNearestChunks(sqlQuery, args, func(rows *sql.Rows) error {
+		for rows.Next() {
+			if len(page.Results) == opts.Limit {
+				data, _ := json.Marshal(last)
+				page.NextCursor = base64.RawURLEncoding.EncodeToString(data)
+				break
+			}
+			var result SearchResult
+			var content string
+			var firstPage, lastPage sql.NullString
+			if err := rows.Scan(&result.DocumentID, &result.FileName, &result.ChunkIndex, &content, &firstPage, &lastPage, &last.ChunkID, &last.Distance); err != nil {
+				return err
+			}
+			result.Score = 1 - last.Distance
+			// Chunks ingested before page numbers were recorded have none.
+			result.Page, _ = strconv.Atoi(firstPage.String)
+			result.EndPage, _ = strconv.Atoi(lastPage.String)
+			result.Snippet = highlight.Snippet(content, query, snippetLength)
+			page.Results = append(page.Results, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return SearchPage{}, err
+	}
+	return page, nil
+}
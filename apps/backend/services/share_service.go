@@ -0,0 +1,176 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrInvalidShare is returned for shares that fail validation.
+	ErrInvalidShare = errors.New("invalid share")
+	// ErrRecipientNotFound is returned when no registered user has the recipient's email.
+	ErrRecipientNotFound = errors.New("no registered user has this email")
+)
+
+// shareSelect selects shares as scanned by scanShare, with the emails of both parties and
+// the name of the shared item.
+const shareSelect = `
+	SELECT s.id, s.owner_id, COALESCE(owner.email, ''), s.recipient_id, COALESCE(recipient.email, ''), s.document_id, s.collection_id,
+	       COALESCE(
+	           (SELECT file_name FROM documents WHERE version_group_id = s.document_id ORDER BY version DESC LIMIT 1),
+	           (SELECT name FROM collections WHERE id = s.collection_id),
+	           ''
+	       ),
+	       s.permission, s.created_at
+	FROM shares s
+	JOIN users owner ON owner.id = s.owner_id
+	JOIN users recipient ON recipient.id = s.recipient_id`
+
+func scanShare(row rowScanner) (models.Share, error) {
+	var share models.Share
+	var documentID, collectionID sql.NullString
+	err := row.Scan(&share.ID, &share.OwnerID, &share.OwnerEmail, &share.RecipientID, &share.RecipientEmail, &documentID, &collectionID, &share.Name, &share.Permission, &share.CreatedAt)
+	share.DocumentID = documentID.String
+	share.CollectionID = collectionID.String
+	return share, err
+}
+
+// ShareTarget is what a share grants access to: exactly one of a document and a collection.
+type ShareTarget struct {
+	DocumentID   string
+	CollectionID string
+}
+
+// ShareWith grants the registered user with the given email permission on one of the
+// owner's personal documents, with all its versions, or collections. Sharing an item with the same
+// user again changes the permission. It returns ErrDocumentNotFound or sql.ErrNoRows if the
+// owner has no such document or collection.
+func ShareWith(ownerID, email string, target ShareTarget, permission string) (models.Share, error) {
+	if _, ok := sharePermissionAccess[permission]; !ok {
+		return models.Share{}, fmt.Errorf("%w: permission must be %s or %s", ErrInvalidShare, PermissionViewer, PermissionChatter)
+	}
+	if (target.DocumentID == "") == (target.CollectionID == "") {
+		return models.Share{}, fmt.Errorf("%w: share either a document or a collection", ErrInvalidShare)
+	}
+
+	// Guests can't receive shares; they have no email, or one of an earlier sign-in method.
+	var recipientID string
+	query := `SELECT id FROM users WHERE lower(email) = lower($1) AND auth_method IS DISTINCT FROM 'guest'`
+	err := database.DB.QueryRow(query, strings.TrimSpace(email)).Scan(&recipientID)
+	if err == sql.ErrNoRows {
+		return models.Share{}, ErrRecipientNotFound
+	} else if err != nil {
+		return models.Share{}, err
+	}
+	if recipientID == ownerID {
+		return models.Share{}, fmt.Errorf("%w: you can't share with yourself", ErrInvalidShare)
+	}
+
+	var insertQuery string
+	var targetID string
+	if target.DocumentID != "" {
+		doc, err := authorizeDocument(target.DocumentID, ownerID, accessOwner)
+		if err != nil {
+			return models.Share{}, err
+		}
+		if doc.WorkspaceID != "" {
+			return models.Share{}, fmt.Errorf("%w: workspace documents are shared by inviting members", ErrInvalidShare)
+		}
+		targetID = doc.VersionGroupID
+		insertQuery = `
+			INSERT INTO shares (id, owner_id, recipient_id, document_id, permission) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (recipient_id, document_id) WHERE document_id IS NOT NULL DO UPDATE SET permission = EXCLUDED.permission
+			RETURNING id`
+	} else {
+		if _, err := getOwnedCollection(target.CollectionID, ownerID); err != nil {
+			return models.Share{}, err
+		}
+		targetID = target.CollectionID
+		insertQuery = `
+			INSERT INTO shares (id, owner_id, recipient_id, collection_id, permission) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (recipient_id, collection_id) WHERE collection_id IS NOT NULL DO UPDATE SET permission = EXCLUDED.permission
+			RETURNING id`
+	}
+
+	var shareID string
+	if err := database.DB.QueryRow(insertQuery, uuid.NewV4().String(), ownerID, recipientID, targetID, permission).Scan(&shareID); err != nil {
+		return models.Share{}, fmt.Errorf("failed to create share: %w", err)
+	}
+	return scanShare(database.DB.QueryRow(shareSelect+` WHERE s.id = $1`, shareID))
+}
+
+// GetGrantedShares lists the shares the owner has granted, optionally only those of one
+// document or collection.
+func GetGrantedShares(ownerID string, target ShareTarget) ([]models.Share, error) {
+	args := []any{ownerID}
+	query := shareSelect + ` WHERE s.owner_id = $1`
+	if target.DocumentID != "" {
+		doc, err := authorizeDocument(target.DocumentID, ownerID, accessOwner)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, doc.VersionGroupID)
+		query += fmt.Sprintf(` AND s.document_id = $%d`, len(args))
+	}
+	if target.CollectionID != "" {
+		args = append(args, target.CollectionID)
+		query += fmt.Sprintf(` AND s.collection_id = $%d`, len(args))
+	}
+	return queryShares(query+` ORDER BY s.created_at DESC`, args...)
+}
+
+// GetReceivedShares lists the shares granted to the user.
+func GetReceivedShares(userID string) ([]models.Share, error) {
+	return queryShares(shareSelect+` WHERE s.recipient_id = $1 ORDER BY s.created_at DESC`, userID)
+}
+
+func queryShares(query string, args ...any) ([]models.Share, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shares := make([]models.Share, 0)
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, share)
+	}
+	return shares, rows.Err()
+}
+
+// UpdateSharePermission changes the permission of a share the owner granted. It returns
+// sql.ErrNoRows if the owner granted no such share.
+func UpdateSharePermission(shareID, ownerID, permission string) (models.Share, error) {
+	if _, ok := sharePermissionAccess[permission]; !ok {
+		return models.Share{}, fmt.Errorf("%w: permission must be %s or %s", ErrInvalidShare, PermissionViewer, PermissionChatter)
+	}
+	result, err := database.DB.Exec(`UPDATE shares SET permission = $1 WHERE id = $2 AND owner_id = $3`, permission, shareID, ownerID)
+	if err != nil {
+		return models.Share{}, err
+	}
+	if err := requireAffectedRow(result); err != nil {
+		return models.Share{}, err
+	}
+	return scanShare(database.DB.QueryRow(shareSelect+` WHERE s.id = $1`, shareID))
+}
+
+// RevokeShare deletes a share. Owners revoke shares they granted and recipients can give up
+// shares granted to them. Access checks read the shares directly, so access ends at once.
+// It returns sql.ErrNoRows if there is no such share.
+func RevokeShare(shareID, userID string) error {
+	result, err := database.DB.Exec(`DELETE FROM shares WHERE id = $1 AND (owner_id = $2 OR recipient_id = $2)`, shareID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
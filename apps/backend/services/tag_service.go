@@ -0,0 +1,133 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// ErrInvalidTag is returned for tag changes that fail validation.
+var ErrInvalidTag = errors.New("invalid tag")
+
+const maxTagNameLength = 64
+
+const tagColumns = `id, user_id, name, created_at`
+
+func scanTag(row rowScanner) (models.Tag, error) {
+	var tag models.Tag
+	err := row.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.CreatedAt)
+	return tag, err
+}
+
+// CreateTag creates a tag for the user.
+func CreateTag(userID, name string) (models.Tag, error) {
+	name, err := validTagName(name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+
+	query := `INSERT INTO tags (id, user_id, name) VALUES ($1, $2, $3) RETURNING ` + tagColumns
+	tag, err := scanTag(database.DB.QueryRow(query, uuid.NewV4().String(), userID, name))
+	if isUniqueViolation(err) {
+		return models.Tag{}, ErrDuplicateName
+	}
+	return tag, err
+}
+
+// GetUserTags lists all the user's tags.
+func GetUserTags(userID string) ([]models.Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags WHERE user_id = $1 ORDER BY lower(name)`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := make([]models.Tag, 0)
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// RenameTag renames the user's tag. It returns sql.ErrNoRows if the user has no such tag.
+func RenameTag(tagID, userID, name string) (models.Tag, error) {
+	name, err := validTagName(name)
+	if err != nil {
+		return models.Tag{}, err
+	}
+
+	query := `UPDATE tags SET name = $1 WHERE id = $2 AND user_id = $3 RETURNING ` + tagColumns
+	tag, err := scanTag(database.DB.QueryRow(query, name, tagID, userID))
+	if isUniqueViolation(err) {
+		return models.Tag{}, ErrDuplicateName
+	}
+	return tag, err
+}
+
+// DeleteTag deletes the user's tag and removes it from all documents. It returns
+// sql.ErrNoRows if the user has no such tag.
+func DeleteTag(tagID, userID string) error {
+	result, err := database.DB.Exec(`DELETE FROM tags WHERE id = $1 AND user_id = $2`, tagID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
+
+// AddTagToDocument tags all versions of the user's document. Adding a tag the document
+// already has is not an error. It returns sql.ErrNoRows if the user has no such tag.
+func AddTagToDocument(documentID, tagID, userID string) error {
+	var exists bool
+	if err := database.DB.QueryRow(`SELECT EXISTS (SELECT 1 FROM tags WHERE id = $1 AND user_id = $2)`, tagID, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	doc, err := authorizeDocument(documentID, userID, accessOwner)
+	if err != nil {
+		return err
+	}
+
+	query := `INSERT INTO document_tags (tag_id, document_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	_, err = database.DB.Exec(query, tagID, doc.VersionGroupID)
+	return err
+}
+
+// RemoveTagFromDocument removes the tag from the user's document. It returns sql.ErrNoRows
+// if the document doesn't have the tag.
+func RemoveTagFromDocument(documentID, tagID, userID string) error {
+	doc, err := authorizeDocument(documentID, userID, accessOwner)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM document_tags
+		WHERE tag_id = (SELECT id FROM tags WHERE id = $1 AND user_id = $3)
+		  AND document_id = $2
+	`
+	result, err := database.DB.Exec(query, tagID, doc.VersionGroupID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
+
+func validTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxTagNameLength {
+		return "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidTag, maxTagNameLength)
+	}
+	return name, nil
+}
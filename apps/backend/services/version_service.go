@@ -0,0 +1,229 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/internal/diff"
+	"strategic-insight-analyst/backend/internal/llm"
+	"strategic-insight-analyst/backend/internal/storage"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+)
+
+var (
+	// ErrInvalidVersionRange is returned when a diff is requested between a version and itself.
+	ErrInvalidVersionRange = errors.New("the versions to compare must differ")
+	// ErrVersionNotProcessed is returned when comparing a version whose text isn't indexed yet.
+	ErrVersionNotProcessed = errors.New("the version hasn't been processed")
+)
+
+// maxSummaryInputChars bounds how much of a diff is sent to the LLM for summarization.
+const maxSummaryInputChars = 60000
+
+// DocumentDiff is the section-level difference between two versions of a document.
+type DocumentDiff struct {
+	DocumentID  string `json:"document_id"`
+	FileName    string `json:"file_name"`
+	FromVersion int    `json:"from_version"`
+	ToVersion   int    `json:"to_version"`
+	diff.Result
+	Summary string `json:"summary"`
+	// SummaryError is set when the diff could be computed but not summarized.
+	SummaryError string `json:"summary_error,omitempty"`
+}
+
+// CreateDocumentVersion records an upload stored in GCS as the next version of the document
+// documentID belongs to, and processes it in the background. Earlier versions keep their
+// files and chunks.
+func CreateDocumentVersion(documentID string, object storage.UploadedObject, fileName, fileType, userID string) (models.Document, error) {
+	latest, err := resolveDocumentVersion(documentID, 0, userID, accessOwner)
+	if err != nil {
+		return models.Document{}, err
+	}
+
+	// The description and metadata describe the document, so they carry over.
+	doc := newDocument(userID, fileName, fileType, object)
+	doc.VersionGroupID = latest.VersionGroupID
+	doc.WorkspaceID = latest.WorkspaceID
+	doc.Description = latest.Description
+	doc.Metadata = latest.Metadata
+	// Collections and tags belong to the version group, so they apply already.
+	doc.CollectionIDs = latest.CollectionIDs
+	doc.TagIDs = latest.TagIDs
+	doc, err = createDocumentRecord(doc)
+	if err != nil {
+		return models.Document{}, err
+	}
+
+	go processStoredDocument(doc, fileType)
+
+	return doc, nil
+}
+
+// GetDocumentVersions lists all versions of the document documentID belongs to, newest
+// first.
+func GetDocumentVersions(documentID, userID string) ([]models.Document, error) {
+	doc, err := authorizeDocument(documentID, userID, accessView)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + documentColumns + ` FROM documents WHERE version_group_id = $1 ORDER BY version DESC`
+	rows, err := database.DB.Query(query, doc.VersionGroupID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]models.Document, 0)
+	for rows.Next() {
+		doc, err := scanDocument(rows)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, doc)
+	}
+	return versions, rows.Err()
+}
+
+// ResolveDocumentVersion returns the given version of the document documentID belongs to,
+// or its latest version if version is 0. A missing version is reported as ErrDocumentNotFound.
+func ResolveDocumentVersion(documentID string, version int, userID string) (models.Document, error) {
+	return resolveDocumentVersion(documentID, version, userID, accessView)
+}
+
+// resolveDocumentVersion is ResolveDocumentVersion requiring access level need.
+func resolveDocumentVersion(documentID string, version int, userID string, need accessLevel) (models.Document, error) {
+	doc, err := authorizeDocument(documentID, userID, need)
+	if err != nil {
+		return models.Document{}, err
+	}
+
+	query := `
+		SELECT ` + documentColumns + ` FROM documents
+		WHERE version_group_id = $1 AND ($2 = 0 OR version = $2)
+		ORDER BY version DESC
+		LIMIT 1
+	`
+	resolved, err := scanDocument(database.DB.QueryRow(query, doc.VersionGroupID, version))
+	if err == sql.ErrNoRows {
+		return models.Document{}, fmt.Errorf("%w: version %d doesn't exist", ErrDocumentNotFound, version)
+	}
+	return resolved, err
+}
+
+// DiffDocumentVersions compares two versions of a document section by section and asks the
+// LLM to summarize the changes. toVersion defaults to the latest version and fromVersion to
+// the one before it.
+func DiffDocumentVersions(documentID string, fromVersion, toVersion int, userID string) (DocumentDiff, error) {
+	to, err := ResolveDocumentVersion(documentID, toVersion, userID)
+	if err != nil {
+		return DocumentDiff{}, err
+	}
+	if fromVersion == 0 {
+		fromVersion = to.Version - 1
+	}
+	if fromVersion == to.Version {
+		return DocumentDiff{}, ErrInvalidVersionRange
+	}
+	if fromVersion < 1 {
+		return DocumentDiff{}, fmt.Errorf("%w: version %d doesn't exist", ErrDocumentNotFound, fromVersion)
+	}
+	from, err := ResolveDocumentVersion(documentID, fromVersion, userID)
+	if err != nil {
+		return DocumentDiff{}, err
+	}
+
+	fromText, err := storedText(from)
+	if err != nil {
+		return DocumentDiff{}, fmt.Errorf("failed to read text of version %d: %w", from.Version, err)
+	}
+	toText, err := storedText(to)
+	if err != nil {
+		return DocumentDiff{}, fmt.Errorf("failed to read text of version %d: %w", to.Version, err)
+	}
+
+	result := DocumentDiff{
+		DocumentID:  to.VersionGroupID,
+		FileName:    to.FileName,
+		FromVersion: from.Version,
+		ToVersion:   to.Version,
+		Result:      diff.Sections(fromText, toText),
+	}
+	if len(result.Changes) == 0 {
+		result.Summary = "The versions have the same content."
+		return result, nil
+	}
+
+	summary, err := llm.SummarizeChanges(to.FileName, from.Version, to.Version, describeChanges(result.Changes))
+	if err != nil {
+		log.Printf("Warning: failed to summarize changes of document %s: %v", to.VersionGroupID, err)
+		result.SummaryError = "Failed to summarize the changes. Please try again."
+		return result, nil
+	}
+	result.Summary = summary
+	return result, nil
+}
+
+// storedText reassembles the text of a processed document from its chunks, so versions are
+// compared by the text that was indexed without extracting it again. The overlap between
+// consecutive chunks is dropped; chunks of different email messages don't overlap.
+func storedText(doc models.Document) (string, error) {
+	if doc.Status != "processed" {
+		return "", fmt.Errorf("%w: version %d is %s", ErrVersionNotProcessed, doc.Version, doc.Status)
+	}
+	rows, err := database.DB.Query(`SELECT content FROM document_chunks WHERE document_id = $1 ORDER BY chunk_index`, doc.ID)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var text strings.Builder
+	var previous string
+	for rows.Next() {
+		var chunk string
+		if err := rows.Scan(&chunk); err != nil {
+			return "", err
+		}
+		text.WriteString(trimChunkOverlap(previous, chunk))
+		previous = chunk
+	}
+	return text.String(), rows.Err()
+}
+
+// trimChunkOverlap returns chunk without the start it repeats from the end of previous.
+func trimChunkOverlap(previous, chunk string) string {
+	runes := []rune(chunk)
+	for _, overlap := range []int{textChunkOverlap, pdfChunkOverlap} {
+		if len(runes) > overlap && strings.HasSuffix(previous, string(runes[:overlap])) {
+			return string(runes[overlap:])
+		}
+	}
+	return chunk
+}
+
+// describeChanges renders the changes as text for the LLM, truncated to maxSummaryInputChars.
+func describeChanges(changes []diff.Change) string {
+	var b strings.Builder
+	for i, change := range changes {
+		var entry strings.Builder
+		fmt.Fprintf(&entry, "[%s]\n", change.Type)
+		if change.Before != "" {
+			fmt.Fprintf(&entry, "Before:\n%s\n", change.Before)
+		}
+		if change.After != "" {
+			fmt.Fprintf(&entry, "After:\n%s\n", change.After)
+		}
+		entry.WriteString("\n")
+
+		if b.Len()+entry.Len() > maxSummaryInputChars {
+			fmt.Fprintf(&b, "(%d further changes omitted)\n", len(changes)-i)
+			break
+		}
+		b.WriteString(entry.String())
+	}
+	return b.String()
+}
@@ -0,0 +1,411 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strategic-insight-analyst/backend/database"
+	"strategic-insight-analyst/backend/models"
+	"strings"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+var (
+	// ErrWorkspaceNotFound is returned when the workspace doesn't exist or the caller isn't a
+	// member. As with documents, the two cases are indistinguishable.
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	// ErrInvalidWorkspace is returned for workspace changes that fail validation.
+	ErrInvalidWorkspace = errors.New("invalid workspace")
+	// ErrWorkspaceNotEmpty is returned when deleting a workspace that still has documents.
+	ErrWorkspaceNotEmpty = errors.New("the workspace still has documents")
+)
+
+const maxWorkspaceNameLength = 255
+
+// workspaceRoleRank orders the roles for membership checks.
+var workspaceRoleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// GetWorkspaceMember returns the user's membership in the workspace, or
+// ErrWorkspaceNotFound if they aren't a member.
+func GetWorkspaceMember(workspaceID, userID string) (models.WorkspaceMember, error) {
+	query := `
+		SELECT m.workspace_id, m.user_id, COALESCE(u.email, ''), m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1 AND m.user_id = $2
+	`
+	var member models.WorkspaceMember
+	err := database.DB.QueryRow(query, workspaceID, userID).Scan(&member.WorkspaceID, &member.UserID, &member.Email, &member.Role, &member.JoinedAt)
+	if err == sql.ErrNoRows {
+		return models.WorkspaceMember{}, ErrWorkspaceNotFound
+	}
+	return member, err
+}
+
+// authorizeWorkspace returns the user's membership if their role is at least minRole,
+// ErrPermissionDenied if it is lower, and ErrWorkspaceNotFound if they aren't a member.
+func authorizeWorkspace(workspaceID, userID, minRole string) (models.WorkspaceMember, error) {
+	member, err := GetWorkspaceMember(workspaceID, userID)
+	if err != nil {
+		return models.WorkspaceMember{}, err
+	}
+	if workspaceRoleRank[member.Role] < workspaceRoleRank[minRole] {
+		return models.WorkspaceMember{}, ErrPermissionDenied
+	}
+	return member, nil
+}
+
+// authorizeUpload checks that the user may add documents to the space of workspaceID,
+// which is their personal space when empty.
+func authorizeUpload(workspaceID, userID string) error {
+	if workspaceID == "" {
+		return nil
+	}
+	_, err := authorizeWorkspace(workspaceID, userID, RoleEditor)
+	return err
+}
+
+// CreateWorkspace creates a workspace with the user as its owner.
+func CreateWorkspace(userID, name string) (models.Workspace, error) {
+	name, err := validWorkspaceName(name)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	defer tx.Rollback()
+
+	workspace := models.Workspace{ID: uuid.NewV4().String(), Name: name, Role: RoleOwner}
+	query := `INSERT INTO workspaces (id, name, created_by) VALUES ($1, $2, $3) RETURNING created_at`
+	if err := tx.QueryRow(query, workspace.ID, workspace.Name, userID).Scan(&workspace.CreatedAt); err != nil {
+		return models.Workspace{}, fmt.Errorf("failed to create workspace: %w", err)
+	}
+	memberQuery := `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3)`
+	if _, err := tx.Exec(memberQuery, workspace.ID, userID, RoleOwner); err != nil {
+		return models.Workspace{}, fmt.Errorf("failed to add workspace owner: %w", err)
+	}
+	return workspace, tx.Commit()
+}
+
+// GetUserWorkspaces lists the workspaces the user is a member of.
+func GetUserWorkspaces(userID string) ([]models.Workspace, error) {
+	query := `
+		SELECT w.id, w.name, m.role, w.created_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY lower(w.name)
+	`
+	rows, err := database.DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	workspaces := make([]models.Workspace, 0)
+	for rows.Next() {
+		var workspace models.Workspace
+		if err := rows.Scan(&workspace.ID, &workspace.Name, &workspace.Role, &workspace.CreatedAt); err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	return workspaces, rows.Err()
+}
+
+// GetWorkspace returns a workspace the user is a member of, with its members.
+func GetWorkspace(workspaceID, userID string) (models.Workspace, error) {
+	member, err := GetWorkspaceMember(workspaceID, userID)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+
+	workspace := models.Workspace{ID: workspaceID, Role: member.Role}
+	query := `SELECT name, created_at FROM workspaces WHERE id = $1`
+	if err := database.DB.QueryRow(query, workspaceID).Scan(&workspace.Name, &workspace.CreatedAt); err != nil {
+		return models.Workspace{}, err
+	}
+
+	membersQuery := `
+		SELECT m.workspace_id, m.user_id, COALESCE(u.email, ''), m.role, m.created_at
+		FROM workspace_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at
+	`
+	rows, err := database.DB.Query(membersQuery, workspaceID)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	defer rows.Close()
+
+	workspace.Members = make([]models.WorkspaceMember, 0)
+	for rows.Next() {
+		var m models.WorkspaceMember
+		if err := rows.Scan(&m.WorkspaceID, &m.UserID, &m.Email, &m.Role, &m.JoinedAt); err != nil {
+			return models.Workspace{}, err
+		}
+		workspace.Members = append(workspace.Members, m)
+	}
+	return workspace, rows.Err()
+}
+
+// RenameWorkspace renames a workspace. Only owners may rename it.
+func RenameWorkspace(workspaceID, userID, name string) (models.Workspace, error) {
+	name, err := validWorkspaceName(name)
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	if _, err := authorizeWorkspace(workspaceID, userID, RoleOwner); err != nil {
+		return models.Workspace{}, err
+	}
+	if _, err := database.DB.Exec(`UPDATE workspaces SET name = $1 WHERE id = $2`, name, workspaceID); err != nil {
+		return models.Workspace{}, err
+	}
+	return GetWorkspace(workspaceID, userID)
+}
+
+// DeleteWorkspace deletes a workspace with its memberships and invitations. Only owners may
+// delete it, and only once its documents have been deleted, so their files aren't orphaned.
+func DeleteWorkspace(workspaceID, userID string) error {
+	if _, err := authorizeWorkspace(workspaceID, userID, RoleOwner); err != nil {
+		return err
+	}
+
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the workspace so no document is added while it is deleted.
+	if _, err := tx.Exec(`SELECT id FROM workspaces WHERE id = $1 FOR UPDATE`, workspaceID); err != nil {
+		return err
+	}
+	var hasDocuments bool
+	if err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM documents WHERE workspace_id = $1)`, workspaceID).Scan(&hasDocuments); err != nil {
+		return err
+	}
+	if hasDocuments {
+		return ErrWorkspaceNotEmpty
+	}
+	if _, err := tx.Exec(`DELETE FROM workspaces WHERE id = $1`, workspaceID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdateWorkspaceMemberRole changes the role of a member. Only owners may change roles, and
+// the last owner can't be demoted. It returns sql.ErrNoRows if there is no such member.
+func UpdateWorkspaceMemberRole(workspaceID, memberID, userID, role string) (models.WorkspaceMember, error) {
+	if _, ok := workspaceRoleRank[role]; !ok {
+		return models.WorkspaceMember{}, fmt.Errorf("%w: role must be %s, %s or %s", ErrInvalidWorkspace, RoleOwner, RoleEditor, RoleViewer)
+	}
+	if _, err := authorizeWorkspace(workspaceID, userID, RoleOwner); err != nil {
+		return models.WorkspaceMember{}, err
+	}
+
+	err := changeMembers(workspaceID, `UPDATE workspace_members SET role = $1 WHERE workspace_id = $2 AND user_id = $3`, role, workspaceID, memberID)
+	if err != nil {
+		return models.WorkspaceMember{}, err
+	}
+	return GetWorkspaceMember(workspaceID, memberID)
+}
+
+// RemoveWorkspaceMember removes a member from a workspace. Owners may remove anyone and
+// members may leave; the last owner can't. It returns sql.ErrNoRows if there is no such
+// member.
+func RemoveWorkspaceMember(workspaceID, memberID, userID string) error {
+	minRole := RoleOwner
+	if memberID == userID {
+		minRole = RoleViewer
+	}
+	if _, err := authorizeWorkspace(workspaceID, userID, minRole); err != nil {
+		return err
+	}
+	return changeMembers(workspaceID, `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`, workspaceID, memberID)
+}
+
+// changeMembers runs a statement changing one membership of the workspace, failing if the
+// workspace would be left without an owner.
+func changeMembers(workspaceID, query string, args ...any) error {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the workspace so concurrent changes can't remove the last owner between them.
+	if _, err := tx.Exec(`SELECT id FROM workspaces WHERE id = $1 FOR UPDATE`, workspaceID); err != nil {
+		return err
+	}
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+	if err := requireAffectedRow(result); err != nil {
+		return err
+	}
+
+	var owners int
+	if err := tx.QueryRow(`SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2`, workspaceID, RoleOwner).Scan(&owners); err != nil {
+		return err
+	}
+	if owners == 0 {
+		return fmt.Errorf("%w: a workspace needs an owner", ErrInvalidWorkspace)
+	}
+	return tx.Commit()
+}
+
+// invitationSelect selects invitations as scanned by scanInvitation.
+const invitationSelect = `
+	SELECT i.id, i.workspace_id, w.name, i.email, i.role, COALESCE(i.invited_by, ''), i.created_at
+	FROM workspace_invitations i
+	JOIN workspaces w ON w.id = i.workspace_id`
+
+func scanInvitation(row rowScanner) (models.WorkspaceInvitation, error) {
+	var invitation models.WorkspaceInvitation
+	err := row.Scan(&invitation.ID, &invitation.WorkspaceID, &invitation.WorkspaceName, &invitation.Email, &invitation.Role, &invitation.InvitedBy, &invitation.CreatedAt)
+	return invitation, err
+}
+
+// InviteToWorkspace invites the user with the email to join the workspace with role. Only
+// owners may invite. Inviting the same email again changes the role.
+func InviteToWorkspace(workspaceID, userID, email, role string) (models.WorkspaceInvitation, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || !strings.Contains(email, "@") {
+		return models.WorkspaceInvitation{}, fmt.Errorf("%w: a valid email is required", ErrInvalidWorkspace)
+	}
+	if _, ok := workspaceRoleRank[role]; !ok {
+		return models.WorkspaceInvitation{}, fmt.Errorf("%w: role must be %s, %s or %s", ErrInvalidWorkspace, RoleOwner, RoleEditor, RoleViewer)
+	}
+	if _, err := authorizeWorkspace(workspaceID, userID, RoleOwner); err != nil {
+		return models.WorkspaceInvitation{}, err
+	}
+
+	var isMember bool
+	memberQuery := `
+		SELECT EXISTS (
+			SELECT 1 FROM workspace_members m JOIN users u ON u.id = m.user_id
+			WHERE m.workspace_id = $1 AND lower(u.email) = lower($2)
+		)
+	`
+	if err := database.DB.QueryRow(memberQuery, workspaceID, email).Scan(&isMember); err != nil {
+		return models.WorkspaceInvitation{}, err
+	}
+	if isMember {
+		return models.WorkspaceInvitation{}, fmt.Errorf("%w: %s is already a member", ErrInvalidWorkspace, email)
+	}
+
+	var invitationID string
+	query := `
+		INSERT INTO workspace_invitations (id, workspace_id, email, role, invited_by) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (workspace_id, lower(email)) DO UPDATE SET role = EXCLUDED.role, invited_by = EXCLUDED.invited_by
+		RETURNING id
+	`
+	if err := database.DB.QueryRow(query, uuid.NewV4().String(), workspaceID, email, role, userID).Scan(&invitationID); err != nil {
+		return models.WorkspaceInvitation{}, fmt.Errorf("failed to create invitation: %w", err)
+	}
+	return scanInvitation(database.DB.QueryRow(invitationSelect+` WHERE i.id = $1`, invitationID))
+}
+
+// GetWorkspaceInvitations lists the pending invitations of a workspace. Only owners may list
+// them.
+func GetWorkspaceInvitations(workspaceID, userID string) ([]models.WorkspaceInvitation, error) {
+	if _, err := authorizeWorkspace(workspaceID, userID, RoleOwner); err != nil {
+		return nil, err
+	}
+	return queryInvitations(invitationSelect+` WHERE i.workspace_id = $1 ORDER BY i.created_at DESC`, workspaceID)
+}
+
+// GetUserInvitations lists the pending invitations addressed to the user's email.
+func GetUserInvitations(userID string) ([]models.WorkspaceInvitation, error) {
+	query := invitationSelect + ` WHERE lower(i.email) = (SELECT lower(email) FROM users WHERE id = $1) ORDER BY i.created_at DESC`
+	return queryInvitations(query, userID)
+}
+
+func queryInvitations(query string, args ...any) ([]models.WorkspaceInvitation, error) {
+	rows, err := database.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := make([]models.WorkspaceInvitation, 0)
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// RevokeWorkspaceInvitation deletes a pending invitation of the workspace. Only owners may
+// revoke invitations. It returns sql.ErrNoRows if there is no such invitation.
+func RevokeWorkspaceInvitation(workspaceID, invitationID, userID string) error {
+	if _, err := authorizeWorkspace(workspaceID, userID, RoleOwner); err != nil {
+		return err
+	}
+	result, err := database.DB.Exec(`DELETE FROM workspace_invitations WHERE id = $1 AND workspace_id = $2`, invitationID, workspaceID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
+
+// AcceptWorkspaceInvitation makes the user a member of the workspace an invitation to their
+// email is for. It returns sql.ErrNoRows if there is no such invitation.
+func AcceptWorkspaceInvitation(invitationID, userID string) (models.Workspace, error) {
+	tx, err := database.DB.Begin()
+	if err != nil {
+		return models.Workspace{}, err
+	}
+	defer tx.Rollback()
+
+	var workspaceID, role string
+	query := `
+		DELETE FROM workspace_invitations
+		WHERE id = $1 AND lower(email) = (SELECT lower(email) FROM users WHERE id = $2)
+		RETURNING workspace_id, role
+	`
+	if err := tx.QueryRow(query, invitationID, userID).Scan(&workspaceID, &role); err != nil {
+		return models.Workspace{}, err
+	}
+	memberQuery := `INSERT INTO workspace_members (workspace_id, user_id, role) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`
+	if _, err := tx.Exec(memberQuery, workspaceID, userID, role); err != nil {
+		return models.Workspace{}, fmt.Errorf("failed to join workspace: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return models.Workspace{}, err
+	}
+	return GetWorkspace(workspaceID, userID)
+}
+
+// DeclineWorkspaceInvitation deletes an invitation to the user's email. It returns
+// sql.ErrNoRows if there is no such invitation.
+func DeclineWorkspaceInvitation(invitationID, userID string) error {
+	query := `DELETE FROM workspace_invitations WHERE id = $1 AND lower(email) = (SELECT lower(email) FROM users WHERE id = $2)`
+	result, err := database.DB.Exec(query, invitationID, userID)
+	if err != nil {
+		return err
+	}
+	return requireAffectedRow(result)
+}
+
+func validWorkspaceName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > maxWorkspaceNameLength {
+		return "", fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidWorkspace, maxWorkspaceNameLength)
+	}
+	return name, nil
+}
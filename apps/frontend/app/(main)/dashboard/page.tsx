@@ -13,6 +13,9 @@ export default function Dashboard() {
   const {
     documents,
     isLoading: docsLoading,
+    isLoadingMore,
+    hasMore,
+    loadMore,
     removeDocument,
     addDocument,
   } = useDocuments();
@@ -39,6 +42,11 @@ export default function Dashboard() {
             isLoading={docsLoading}
             removeDocument={removeDocument}
           />
+          {hasMore && (
+            <Button onClick={loadMore} disabled={isLoadingMore} variant="outline">
+              Load more
+            </Button>
+          )}
         </div>
       </div>
     )
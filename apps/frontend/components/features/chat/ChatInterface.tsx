@@ -23,8 +23,16 @@ interface ChatInterfaceProps {
  * A component that provides a chat interface for a specific document.
  */
 const ChatInterface = ({ documentId }: ChatInterfaceProps) => {
-  const { messages, isLoading, error, sendMessage, setMessages } =
-    useChat(documentId);
+  const {
+    messages,
+    isLoading,
+    isLoadingOlder,
+    hasMore,
+    error,
+    sendMessage,
+    loadOlder,
+    setMessages,
+  } = useChat(documentId);
   const [newMessage, setNewMessage] = useState("");
   const messagesEndRef = useRef<HTMLDivElement>(null);
   const [isModalOpen, setIsModalOpen] = useState(false);
@@ -43,9 +51,11 @@ const ChatInterface = ({ documentId }: ChatInterfaceProps) => {
     messagesEndRef.current?.scrollIntoView({ behavior: "smooth" });
   };
 
+  // Follow new and streaming messages, but stay put when older ones are prepended.
+  const lastMessage = messages[messages.length - 1];
   useEffect(() => {
     scrollToBottom();
-  }, [messages]);
+  }, [lastMessage]);
 
   useEffect(() => {
     if (error) {
@@ -99,6 +109,18 @@ const ChatInterface = ({ documentId }: ChatInterfaceProps) => {
       </header>
       <main className="flex-1 overflow-y-auto p-4 md:p-6">
         <div className="space-y-6">
+          {hasMore && (
+            <div className="flex justify-center">
+              <Button
+                variant="outline"
+                size="sm"
+                onClick={loadOlder}
+                disabled={isLoadingOlder}
+              >
+                Load earlier messages
+              </Button>
+            </div>
+          )}
           {messages.length === 0 ? (
             <div className="flex flex-col items-center justify-center h-full text-center">
               <h2 className="text-xl font-semibold text-gray-600">
@@ -43,8 +43,13 @@ const FileDropzone = ({ onUploadComplete }: FileDropzoneProps) => {
     accept: {
       "application/pdf": [".pdf"],
       "text/plain": [".txt"],
+      "image/png": [".png"],
+      "image/jpeg": [".jpg", ".jpeg"],
+      "image/tiff": [".tif", ".tiff"],
+      "message/rfc822": [".eml"],
+      "application/mbox": [".mbox"],
     },
-    maxSize: 10 * 1024 * 1024, // 10MB
+    maxSize: 500 * 1024 * 1024, // 500MB, matches the backend's default MAX_UPLOAD_BYTES
     multiple: false,
   });
 
@@ -101,7 +106,7 @@ const FileDropzone = ({ onUploadComplete }: FileDropzoneProps) => {
           <p className="text-lg font-semibold">
             Drag & drop a file here, or click to select one
           </p>
-          <p className="text-sm text-gray-500">PDF or TXT files, up to 10MB</p>
+          <p className="text-sm text-gray-500">PDF, TXT, image or email files, up to 500MB</p>
         </div>
       )}
       {error && (
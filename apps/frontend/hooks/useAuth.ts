@@ -13,6 +13,7 @@ import {
   signInAsGuest as firebaseSignInAsGuest,
   signInWithGoogle as firebaseSignInWithGoogle,
   signOut as firebaseSignOut,
+  upgradeGuestWithGoogle as firebaseUpgradeGuestWithGoogle,
 } from "../lib/firebase";
 import * as authService from "../services/api/authService";
 
@@ -70,6 +71,24 @@ export const useAuth = () => {
     });
   };
 
+  /**
+   * Upgrades the signed-in guest to a Google account, keeping their data.
+   * On success, updates the user in the backend, which stops the guest account's expiry.
+   */
+  const upgradeGuest = async () => {
+    startTransition(async () => {
+      try {
+        const idToken = await firebaseUpgradeGuestWithGoogle();
+
+        if (idToken) {
+          await authService.signUp(idToken);
+        }
+      } catch (error: any) {
+        setError(parseFirebaseError(error));
+      }
+    });
+  };
+
   /**
    * Initiates the email/password sign-in process.
    * On success, signs up the user in the backend and redirects to the dashboard.
@@ -116,6 +135,7 @@ export const useAuth = () => {
     signInWithGoogle,
     signInAsGuest,
     signInAsEmail,
+    upgradeGuest,
     signOut,
     error,
     signUp: authService.signUp,
@@ -10,23 +10,26 @@ import { v4 as uuidv4 } from "uuid";
 /**
  * Custom hook for managing chat functionality.
  * @param documentId - The ID of the document to chat with.
- * @returns An object with chat messages, loading state, error state, and functions to send messages and fetch history.
+ * @returns An object with chat messages, loading state, error state, pagination state, and functions to send messages, fetch history and load older messages.
  */
 export const useChat = (documentId: string) => {
   const [messages, setMessages] = useState<ChatMessage[]>([]);
   const [isLoading, setIsLoading] = useState(false);
+  const [isLoadingOlder, setIsLoadingOlder] = useState(false);
+  const [hasMore, setHasMore] = useState(false);
   const [error, setError] = useState<string | null>(null);
 
   /**
-   * Fetches the chat history for the current document.
+   * Fetches the latest page of the chat history for the current document.
    */
   const fetchHistory = useCallback(async () => {
     if (!documentId) return;
     setIsLoading(true);
     setError(null);
     try {
-      const history = await apiGetChatHistory(documentId);
-      setMessages(history);
+      const page = await apiGetChatHistory(documentId);
+      setMessages(page.messages);
+      setHasMore(page.hasMore);
     } catch (err) {
       setError("Failed to fetch chat history. Please try again later.");
       console.error(err);
@@ -39,6 +42,26 @@ export const useChat = (documentId: string) => {
     fetchHistory();
   }, [fetchHistory]);
 
+  /**
+   * Prepends the page of messages preceding the oldest loaded message.
+   */
+  const loadOlder = useCallback(async () => {
+    if (!documentId || messages.length === 0) return;
+    setIsLoadingOlder(true);
+    try {
+      const page = await apiGetChatHistory(documentId, {
+        before: messages[0].id,
+      });
+      setMessages((prev) => [...page.messages, ...prev]);
+      setHasMore(page.hasMore);
+    } catch (err) {
+      setError("Failed to fetch chat history. Please try again later.");
+      console.error(err);
+    } finally {
+      setIsLoadingOlder(false);
+    }
+  }, [documentId, messages]);
+
   /**
    * Sends a new message to the chat.
    * @param messageContent - The content of the message to send.
@@ -99,5 +122,15 @@ export const useChat = (documentId: string) => {
     }
   };
 
-  return { messages, isLoading, error, sendMessage, fetchHistory, setMessages };
+  return {
+    messages,
+    isLoading,
+    isLoadingOlder,
+    hasMore,
+    error,
+    sendMessage,
+    fetchHistory,
+    loadOlder,
+    setMessages,
+  };
 };
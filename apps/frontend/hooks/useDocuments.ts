@@ -9,22 +9,27 @@ import {
 
 /**
  * Custom hook for managing documents.
- * @returns An object with the list of documents, loading state, error state, and functions to fetch, add, and remove documents.
+ * @returns An object with the loaded documents, loading state, error state, pagination state, and functions to fetch, load more, add, and remove documents.
  */
 export const useDocuments = () => {
   const [documents, setDocuments] = useState<Document[]>([]);
+  const [nextCursor, setNextCursor] = useState<string | undefined>();
+  const [totalCount, setTotalCount] = useState(0);
   const [isLoading, setIsLoading] = useState(true);
+  const [isLoadingMore, setIsLoadingMore] = useState(false);
   const [error, setError] = useState<string | null>(null);
 
   /**
-   * Fetches the list of documents from the server.
+   * Fetches the first page of documents from the server.
    */
   const fetchDocuments = useCallback(async () => {
     setIsLoading(true);
     setError(null);
     try {
-      const data = await apiGetDocuments();
-      setDocuments(data);
+      const page = await apiGetDocuments();
+      setDocuments(page.documents);
+      setNextCursor(page.next_cursor);
+      setTotalCount(page.total_count);
     } catch (err) {
       setError("Failed to fetch documents");
       console.error(err);
@@ -37,6 +42,27 @@ export const useDocuments = () => {
     fetchDocuments();
   }, [fetchDocuments]);
 
+  /**
+   * Appends the next page of documents, if there is one.
+   */
+  const loadMore = useCallback(async () => {
+    if (!nextCursor) {
+      return;
+    }
+    setIsLoadingMore(true);
+    try {
+      const page = await apiGetDocuments({ cursor: nextCursor });
+      setDocuments((prevDocs) => [...prevDocs, ...page.documents]);
+      setNextCursor(page.next_cursor);
+      setTotalCount(page.total_count);
+    } catch (err) {
+      setError("Failed to fetch documents");
+      console.error(err);
+    } finally {
+      setIsLoadingMore(false);
+    }
+  }, [nextCursor]);
+
   const pollingRef = useRef<Set<string>>(new Set());
 
   useEffect(() => {
@@ -96,6 +122,7 @@ export const useDocuments = () => {
    */
   const addDocument = (document: Document) => {
     setDocuments((prevDocs) => [document, ...prevDocs]);
+    setTotalCount((count) => count + 1);
   };
 
   /**
@@ -104,6 +131,7 @@ export const useDocuments = () => {
    */
   const removeDocument = async (documentId: string) => {
     setDocuments((prevDocs) => prevDocs.filter((doc) => doc.id !== documentId));
+    setTotalCount((count) => Math.max(count - 1, 0));
     try {
       await apiDeleteDocument(documentId);
     } catch (err) {
@@ -117,8 +145,12 @@ export const useDocuments = () => {
   return {
     documents,
     isLoading,
+    isLoadingMore,
     error,
+    totalCount,
+    hasMore: nextCursor !== undefined,
     fetchDocuments,
+    loadMore,
     addDocument,
     removeDocument,
   };
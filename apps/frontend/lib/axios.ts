@@ -1,6 +1,7 @@
 import axios from "axios";
 import { auth } from "./firebase";
 import { toast } from "sonner";
+import { getActiveWorkspaceId } from "./workspace";
 
 const axiosInstance = axios.create({
   baseURL: process.env.NEXT_PUBLIC_API_URL,
@@ -13,6 +14,10 @@ axiosInstance.interceptors.request.use(
       const token = await user.getIdToken();
       config.headers.Authorization = `Bearer ${token}`;
     }
+    const workspaceId = getActiveWorkspaceId();
+    if (workspaceId) {
+      config.headers["X-Workspace-ID"] = workspaceId;
+    }
     return config;
   },
   (error) => {
@@ -3,6 +3,7 @@ import {
   GoogleAuthProvider,
   signInWithPopup,
   signInAnonymously,
+  linkWithPopup,
   signOut as firebaseSignOut,
   onAuthStateChanged,
 } from "firebase/auth";
@@ -73,6 +74,29 @@ export const signInAsGuest = async () => {
   }
 };
 
+/**
+ * Upgrades the signed-in guest to a Google account by linking it to their anonymous
+ * account, which keeps the user's ID and with it their documents.
+ * @returns A promise that resolves with the user's refreshed ID token.
+ * @throws An error if no guest is signed in or linking fails, e.g. because the Google
+ * account is already in use.
+ */
+export const upgradeGuestWithGoogle = async () => {
+  const user = auth.currentUser;
+  if (!user || !user.isAnonymous) {
+    throw new Error("Only guests can be upgraded");
+  }
+  try {
+    const result = await linkWithPopup(user, provider);
+    // The refreshed token names the new sign-in provider.
+    const idToken = await result.user.getIdToken(true);
+    return idToken;
+  } catch (error) {
+    console.error("Error during guest upgrade:", error);
+    throw error;
+  }
+};
+
 /**
  * Signs out the current user.
  * @throws An error if the sign-out process fails.
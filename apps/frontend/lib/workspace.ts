@@ -0,0 +1,21 @@
+const STORAGE_KEY = "activeWorkspaceId";
+
+/**
+ * Returns the ID of the workspace API requests act in, or null for the personal space.
+ */
+export const getActiveWorkspaceId = (): string | null => {
+  if (typeof window === "undefined") return null;
+  return window.localStorage.getItem(STORAGE_KEY);
+};
+
+/**
+ * Selects the workspace API requests act in; null selects the personal space.
+ * @param workspaceId - The ID of the workspace, or null.
+ */
+export const setActiveWorkspaceId = (workspaceId: string | null) => {
+  if (workspaceId) {
+    window.localStorage.setItem(STORAGE_KEY, workspaceId);
+  } else {
+    window.localStorage.removeItem(STORAGE_KEY);
+  }
+};
@@ -1,19 +1,23 @@
 import { auth } from "@/lib/firebase";
+import { getActiveWorkspaceId } from "@/lib/workspace";
 import axios from "../../lib/axios";
 import { ChatMessage } from "../../types";
 
 /**
- * Fetches the chat history for a specific document.
+ * Fetches a page of the chat history for a specific document. Without a cursor the page
+ * holds the latest messages.
  * @param documentId - The ID of the document.
- * @returns A promise that resolves to an array of chat messages.
+ * @param params - The ID of the oldest ("before") or newest ("after") loaded message, and the page size.
+ * @returns A promise that resolves to the messages in chronological order and whether there are more.
  */
 export const getChatHistory = async (
-  documentId: string
-): Promise<ChatMessage[]> => {
-  const response = await axios.get(`/api/chat/${documentId}`);
+  documentId: string,
+  params: { before?: string; after?: string; limit?: number } = {}
+): Promise<{ messages: ChatMessage[]; hasMore: boolean }> => {
+  const response = await axios.get(`/api/chat/${documentId}`, { params });
   // The backend returns attached_documents as a JSON string.
   // We need to parse it on the frontend.
-  return response.data.map((message: any) => {
+  const messages = response.data.messages.map((message: any) => {
     if (
       message.attached_documents &&
       typeof message.attached_documents === "string"
@@ -36,6 +40,7 @@ export const getChatHistory = async (
     }
     return { ...message, attachedDocuments: message.attached_documents || [] };
   });
+  return { messages, hasMore: response.data.has_more };
 };
 
 /**
@@ -57,6 +62,7 @@ export const postChatMessage = async (
   }
   const token = await user.getIdToken();
   const authString = `Bearer ${token}`;
+  const workspaceId = getActiveWorkspaceId();
 
   const response = await fetch(process.env.NEXT_PUBLIC_API_URL + "/api/chat", {
     method: "POST",
@@ -64,6 +70,7 @@ export const postChatMessage = async (
       "Content-Type": "application/json",
       Accept: "text/event-stream",
       Authorization: authString,
+      ...(workspaceId ? { "X-Workspace-ID": workspaceId } : {}),
     },
     body: JSON.stringify({
       document_id: documentId,
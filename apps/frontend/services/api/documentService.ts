@@ -1,5 +1,5 @@
 import axios from "../../lib/axios";
-import { Document } from "../../types";
+import { Document, DocumentListParams, DocumentPage } from "../../types";
 
 /**
  * Uploads a document.
@@ -42,11 +42,18 @@ export const checkDocumentStatus = async (
 };
 
 /**
- * Fetches all documents for the user.
- * @returns A promise that resolves to an array of documents.
+ * Fetches a page of the user's documents.
+ * @param params - Pagination, sorting and filtering options.
+ * @returns A promise that resolves to a page of documents.
  */
-export const getDocuments = async (): Promise<Document[]> => {
-  const response = await axios.get("/api/documents");
+export const getDocuments = async (
+  params: DocumentListParams = {}
+): Promise<DocumentPage> => {
+  const response = await axios.get("/api/documents", {
+    params,
+    // Repeat array parameters (status=a&status=b) as the API expects.
+    paramsSerializer: { indexes: null },
+  });
   return response.data;
 };
 
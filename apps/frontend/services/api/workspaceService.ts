@@ -0,0 +1,115 @@
+import axios from "../../lib/axios";
+import {
+  Workspace,
+  WorkspaceInvitation,
+  WorkspaceMember,
+  WorkspaceRole,
+} from "../../types";
+
+/**
+ * Fetches the workspaces the user is a member of.
+ * @returns A promise that resolves to the workspaces.
+ */
+export const getWorkspaces = async (): Promise<Workspace[]> => {
+  const response = await axios.get("/api/workspaces");
+  return response.data;
+};
+
+/**
+ * Fetches a workspace with its members.
+ * @param workspaceId - The ID of the workspace.
+ * @returns A promise that resolves to the workspace.
+ */
+export const getWorkspace = async (workspaceId: string): Promise<Workspace> => {
+  const response = await axios.get(`/api/workspaces/${workspaceId}`);
+  return response.data;
+};
+
+/**
+ * Creates a workspace owned by the user.
+ * @param name - The name of the workspace.
+ * @returns A promise that resolves to the new workspace.
+ */
+export const createWorkspace = async (name: string): Promise<Workspace> => {
+  const response = await axios.post("/api/workspaces", { name });
+  return response.data;
+};
+
+/**
+ * Invites someone to a workspace by email.
+ * @param workspaceId - The ID of the workspace.
+ * @param email - The email to invite.
+ * @param role - The role the invitee gets on accepting.
+ * @returns A promise that resolves to the invitation.
+ */
+export const inviteToWorkspace = async (
+  workspaceId: string,
+  email: string,
+  role: WorkspaceRole
+): Promise<WorkspaceInvitation> => {
+  const response = await axios.post(
+    `/api/workspaces/${workspaceId}/invitations`,
+    { email, role }
+  );
+  return response.data;
+};
+
+/**
+ * Changes the role of a workspace member.
+ * @param workspaceId - The ID of the workspace.
+ * @param userId - The ID of the member.
+ * @param role - The new role.
+ * @returns A promise that resolves to the updated member.
+ */
+export const updateWorkspaceMember = async (
+  workspaceId: string,
+  userId: string,
+  role: WorkspaceRole
+): Promise<WorkspaceMember> => {
+  const response = await axios.patch(
+    `/api/workspaces/${workspaceId}/members/${userId}`,
+    { role }
+  );
+  return response.data;
+};
+
+/**
+ * Removes a member from a workspace; members leave by removing themselves.
+ * @param workspaceId - The ID of the workspace.
+ * @param userId - The ID of the member.
+ */
+export const removeWorkspaceMember = async (
+  workspaceId: string,
+  userId: string
+): Promise<void> => {
+  await axios.delete(`/api/workspaces/${workspaceId}/members/${userId}`);
+};
+
+/**
+ * Fetches the pending workspace invitations addressed to the user.
+ * @returns A promise that resolves to the invitations.
+ */
+export const getInvitations = async (): Promise<WorkspaceInvitation[]> => {
+  const response = await axios.get("/api/invitations");
+  return response.data;
+};
+
+/**
+ * Accepts a workspace invitation.
+ * @param invitationId - The ID of the invitation.
+ * @returns A promise that resolves to the joined workspace.
+ */
+export const acceptInvitation = async (
+  invitationId: string
+): Promise<Workspace> => {
+  const response = await axios.post(`/api/invitations/${invitationId}/accept`);
+  return response.data;
+};
+
+/**
+ * Declines a workspace invitation.
+ * @param invitationId - The ID of the invitation.
+ */
+export const declineInvitation = async (invitationId: string): Promise<void> => {
+  await axios.delete(`/api/invitations/${invitationId}`);
+};
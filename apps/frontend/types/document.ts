@@ -12,8 +12,46 @@ export interface Document {
   gcs_path: string;
   /** The ID of the user who uploaded the document. */
   user_id: string;
+  /** The workspace owning the document; absent for personal documents. */
+  workspace_id?: string;
   /** The processing status of the document. */
   status: string;
   /** An optional error message if processing failed. */
   processingError?: string;
 }
+
+/**
+ * A page of the document list.
+ */
+export interface DocumentPage {
+  /** The documents on this page. */
+  documents: Document[];
+  /** The cursor for the next page; absent on the last page. */
+  next_cursor?: string;
+  /** The number of matching documents across all pages. */
+  total_count: number;
+}
+
+/**
+ * Options for listing documents.
+ */
+export interface DocumentListParams {
+  /** The next_cursor of the previous page. */
+  cursor?: string;
+  /** The page size. */
+  limit?: number;
+  /** The sort order: "date", "name", "status" or "size". */
+  sort?: "date" | "name" | "status" | "size";
+  /** The sort direction. */
+  order?: "asc" | "desc";
+  /** Only list documents with one of these statuses. */
+  status?: string[];
+  /** Only list documents with one of these content types. */
+  type?: string[];
+  /** Only list documents whose name contains this text. */
+  q?: string;
+  /** Only list documents created at or after this date or timestamp. */
+  created_after?: string;
+  /** Only list documents created before this date or timestamp. */
+  created_before?: string;
+}
@@ -1,2 +1,3 @@
 export * from "./document";
 export * from "./chat";
+export * from "./workspace";
@@ -0,0 +1,42 @@
+/**
+ * A role in a workspace. Owners manage the workspace, editors add and change documents
+ * and viewers read and chat with them.
+ */
+export type WorkspaceRole = "owner" | "editor" | "viewer";
+
+/**
+ * A member of a workspace.
+ */
+export interface WorkspaceMember {
+  workspace_id: string;
+  user_id: string;
+  email: string;
+  role: WorkspaceRole;
+  joined_at: string;
+}
+
+/**
+ * A team workspace owning documents.
+ */
+export interface Workspace {
+  id: string;
+  name: string;
+  /** The current user's role in the workspace. */
+  role: WorkspaceRole;
+  /** The members; only included when a single workspace is fetched. */
+  members?: WorkspaceMember[];
+  created_at: string;
+}
+
+/**
+ * A pending invitation to join a workspace.
+ */
+export interface WorkspaceInvitation {
+  id: string;
+  workspace_id: string;
+  workspace_name: string;
+  email: string;
+  role: WorkspaceRole;
+  invited_by?: string;
+  created_at: string;
+}